@@ -0,0 +1,74 @@
+package kubeless
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// gffAttributes are the tags of the ninth column of a gff3 line with
+// their values
+type gffAttributes map[string][]string
+
+// first returns the first value of the tag
+func (a gffAttributes) first(tag string) string {
+	if v := a[tag]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// parseAttributes parses the ninth column of a gff3 line. The tag=value
+// pairs are separated by semicolons and multiple values of a tag by
+// commas, both the tags and the values are percent decoded after being
+// split.
+func parseAttributes(col string) (gffAttributes, error) {
+	attrs := make(gffAttributes)
+	if col == "." {
+		return attrs, nil
+	}
+	for _, pair := range strings.Split(col, ";") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return attrs, fmt.Errorf("attribute %s is not a tag=value pair", pair)
+		}
+		tag, err := url.PathUnescape(strings.TrimSpace(kv[0]))
+		if err != nil {
+			return attrs, fmt.Errorf("error in decoding tag %s %s", kv[0], err)
+		}
+		if len(tag) == 0 {
+			return attrs, fmt.Errorf("attribute %s has no tag", pair)
+		}
+		for _, v := range strings.Split(kv[1], ",") {
+			dv, err := url.PathUnescape(v)
+			if err != nil {
+				return attrs, fmt.Errorf("error in decoding value %s of %s %s", v, tag, err)
+			}
+			attrs[tag] = append(attrs[tag], dv)
+		}
+	}
+	return attrs, nil
+}
+
+// featIds gives the IDs of the features, the ones without an ID
+// attribute, such as exons, get one made from their first parent, type
+// and position among the siblings of that type
+type featIds map[string]int
+
+// id returns the ID of the feature of type t, it is empty if the feature
+// has neither ID nor Parent attributes
+func (f featIds) id(t string, attrs gffAttributes) string {
+	if id := attrs.first("ID"); len(id) > 0 {
+		return id
+	}
+	p := attrs.first("Parent")
+	if len(p) == 0 {
+		return ""
+	}
+	sk := fmt.Sprintf("%s-%s", p, t)
+	f[sk]++
+	return fmt.Sprintf("%s-%d", sk, f[sk])
+}
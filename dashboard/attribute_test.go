@@ -0,0 +1,84 @@
+package kubeless
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		col     string
+		want    gffAttributes
+		wantErr bool
+	}{
+		{"empty column", ".", gffAttributes{}, false},
+		{
+			"single pair",
+			"ID=DDB_G0267178",
+			gffAttributes{"ID": {"DDB_G0267178"}},
+			false,
+		},
+		{
+			"multiple values",
+			"ID=exon1;Parent=mRNA1,mRNA2",
+			gffAttributes{"ID": {"exon1"}, "Parent": {"mRNA1", "mRNA2"}},
+			false,
+		},
+		{
+			"percent encoded",
+			"Name=a%3Bb%2Cc;Note=50%25%20done",
+			gffAttributes{"Name": {"a;b,c"}, "Note": {"50% done"}},
+			false,
+		},
+		{
+			"trailing semicolon",
+			"ID=gene1;",
+			gffAttributes{"ID": {"gene1"}},
+			false,
+		},
+		{
+			"spaces around tag",
+			" ID =gene1; Name=tub A",
+			gffAttributes{"ID": {"gene1"}, "Name": {"tub A"}},
+			false,
+		},
+		{"missing value", "ID", nil, true},
+		{"missing tag", "=gene1", nil, true},
+		{"invalid escape", "Name=a%zz", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAttributes(tt.col)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAttributes(%q) error %v, want error %t", tt.col, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAttributes(%q) = %v, want %v", tt.col, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeatIds(t *testing.T) {
+	fids := make(featIds)
+	tests := []struct {
+		name  string
+		ftype string
+		attrs gffAttributes
+		want  string
+	}{
+		{"own ID", "gene", gffAttributes{"ID": {"gene1"}}, "gene1"},
+		{"first exon", "exon", gffAttributes{"Parent": {"mRNA1"}}, "mRNA1-exon-1"},
+		{"second exon", "exon", gffAttributes{"Parent": {"mRNA1", "mRNA2"}}, "mRNA1-exon-2"},
+		{"other type", "CDS", gffAttributes{"Parent": {"mRNA1"}}, "mRNA1-CDS-1"},
+		{"without parent", "region", gffAttributes{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fids.id(tt.ftype, tt.attrs); got != tt.want {
+				t.Errorf("id() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
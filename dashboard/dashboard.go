@@ -1,16 +1,18 @@
 package kubeless
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictybase-playground/kubeless-gofn/shared"
 	"github.com/go-chi/chi"
 	"github.com/kubeless/kubeless/pkg/functions"
 	"github.com/minio/minio-go"
@@ -19,11 +21,8 @@ import (
 )
 
 var (
-	titleErrKey   = errors.GenSym()
-	pointerErrKey = errors.GenSym()
-	paramErrKey   = errors.GenSym()
-	hasAPIServer  = false
-	apiPort       = 33333
+	hasAPIServer = false
+	apiPort      = 33333
 )
 
 const (
@@ -38,22 +37,21 @@ type MetaData struct {
 	Rank       string `json:"rank,omitempty"`
 	Bucket     string `json:"bucket"`
 	File       string `json:"file"`
+	// FastaFile is the optional FASTA file of the genome sequence in the
+	// same bucket
+	FastaFile string `json:"fasta_file,omitempty"`
+	// Strict rejects the gff3 file if any of its lines is invalid,
+	// otherwise the invalid lines are skipped
+	Strict bool `json:"strict,omitempty"`
 }
 
-func getStorage() (Storage, error) {
-	var st Storage
-	rhost := os.Getenv("REDIS_MASTER_SERVICE_HOST")
-	rport := os.Getenv("REDIS_MASTER_SERVICE_PORT")
-	shost := os.Getenv("REDIS_SLAVE_SERVICE_HOST")
-	sport := os.Getenv("REDIS_SLAVE_SERVICE_PORT")
-	if len(rhost) > 0 && len(shost) > 0 {
-		st = NewRedisStorage(
-			fmt.Sprintf("%s:%s", rhost, rport),
-			fmt.Sprintf("%s:%s", shost, sport),
-		)
-		return st, nil
-	}
-	return st, fmt.Errorf("no storage backend available")
+type reportJsonAPI struct {
+	Meta *gffReport `json:"meta"`
+}
+
+// getStorage returns the redis Storage configured from the environment
+func getStorage() (shared.Storage, error) {
+	return shared.NewStorage(shared.RedisConfigFromEnv())
 }
 
 func init() {
@@ -77,6 +75,12 @@ func init() {
 		}
 		fmt.Fprintf(w, "%s", payload)
 	})
+	r.Get("/genomes/{taxonid}/{seqid}/features", regionHandler(st))
+	r.Get("/genomes/{taxonid}/{seqid}/sequence", sequenceHandler(st))
+	r.Get("/genomes/{taxonid}/features/{id}", featureHandler(st))
+	r.Get("/genomes/{taxonid}/features/{id}/children", relatedHandler(st, "children"))
+	r.Get("/genomes/{taxonid}/features/{id}/parents", relatedHandler(st, "parents"))
+	r.Get("/genomes/{taxonid}/features/{id}/sequence", featureSequenceHandler(st))
 	go func() {
 		lport := fmt.Sprintf(":%d", apiPort)
 		log.Printf("starting localhost server on %s", lport)
@@ -137,12 +141,35 @@ func Handler(event functions.Event, ctx functions.Context) (string, error) {
 				fmt.Sprintf("error in fetching file from s3 %s", err),
 			)
 		}
+		var gr io.Reader = gf
+		if meta.Strict {
+			b, err := ioutil.ReadAll(gf)
+			if err != nil {
+				return internalServerError(
+					w,
+					fmt.Sprintf("error in reading file from s3 %s", err),
+				)
+			}
+			rep, err := validateGFF3(bytes.NewReader(b))
+			if err != nil {
+				return internalServerError(
+					w,
+					fmt.Sprintf("error in validating file %s", err),
+				)
+			}
+			if rep.Skipped > 0 {
+				return invalidGFF3Error(w, rep)
+			}
+			gr = bytes.NewReader(b)
+		}
 		log.Println("storing information of gff3 file....")
 		key := fmt.Sprintf("%s-%s", KEY_PREFIX, meta.TaxonId)
+		rep := newGFFReport()
 		err = storeGFFInforamtion(
-			gf,
+			gr,
 			storage,
 			key,
+			rep,
 			[]string{"chromosome", "gene", "pseudogene"}...,
 		)
 		if err != nil {
@@ -165,7 +192,34 @@ func Handler(event functions.Event, ctx functions.Context) (string, error) {
 				fmt.Sprintf("error in saving metadata %s", err),
 			)
 		}
-		return fmt.Sprintf("%d OK", http.StatusOK), nil
+		if len(meta.FastaFile) > 0 {
+			log.Printf("going to fetch file %s from bucket %s", meta.FastaFile, meta.Bucket)
+			ff, err := s3Client.GetObject(meta.Bucket, meta.FastaFile, minio.GetObjectOptions{})
+			if err != nil {
+				return internalServerError(
+					w,
+					fmt.Sprintf("error in fetching fasta file from s3 %s", err),
+				)
+			}
+			log.Println("storing sequences of fasta file....")
+			if err := storeFasta(ff, storage, key); err != nil {
+				return internalServerError(
+					w,
+					fmt.Sprintf("error in saving fasta file %s", err),
+				)
+			}
+		}
+		if rep.Skipped > 0 {
+			log.Printf("skipped %d invalid lines of gff3 file", rep.Skipped)
+		}
+		ct, err := json.Marshal(&reportJsonAPI{Meta: rep})
+		if err != nil {
+			return internalServerError(
+				w,
+				fmt.Sprintf("error in marshaling report %s", err),
+			)
+		}
+		return string(ct), nil
 	}
 	if r.Method == "GET" {
 		if !hasAPIServer {
@@ -175,12 +229,26 @@ func Handler(event functions.Event, ctx functions.Context) (string, error) {
 			)
 		}
 		url := fmt.Sprintf("http://localhost:%d%s", apiPort, r.URL.Path)
+		if len(r.URL.RawQuery) > 0 {
+			url = fmt.Sprintf("%s?%s", url, r.URL.RawQuery)
+		}
 
-		resp, err := http.Get(url)
+		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
-			return httpError(
+			return internalServerError(
+				w,
+				fmt.Sprintf("error in creating request %s", err),
+			)
+		}
+		// needed for making the links to the function
+		req.Host = r.Host
+		for _, h := range []string{"X-Forwarded-Proto", "X-Original-Uri"} {
+			req.Header.Set(h, r.Header.Get(h))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return internalServerError(
 				w,
-				resp.StatusCode,
 				fmt.Sprintf("error in retrieving data %s", err),
 			)
 		}
@@ -201,7 +269,7 @@ func Handler(event functions.Event, ctx functions.Context) (string, error) {
 		}
 		return string(b), nil
 	}
-	json, status, err := JSONAPIError(
+	json, status, err := shared.JSONAPIError(
 		apherror.ErrMethodNotAllowed.New(
 			"%s not allowed",
 			r.Method,
@@ -210,43 +278,14 @@ func Handler(event functions.Event, ctx functions.Context) (string, error) {
 	return json, err
 }
 
-//JSONAPIError generate JSONAPI formatted http error from an error object
-func JSONAPIError(err error) (string, int, error) {
-	status := errhttp.GetStatusCode(err, http.StatusInternalServerError)
-	title, _ := errors.GetData(err, titleErrKey).(string)
-	jsnErr := apherror.Error{
-		Status: strconv.Itoa(status),
-		Title:  title,
-		Detail: errhttp.GetErrorBody(err),
-		Meta: map[string]interface{}{
-			"creator": "kubeless gofn error",
-		},
-	}
-	errSource := new(apherror.ErrorSource)
-	pointer, ok := errors.GetData(err, pointerErrKey).(string)
-	if ok {
-		errSource.Pointer = pointer
-	}
-	param, ok := errors.GetData(err, paramErrKey).(string)
-	if ok {
-		errSource.Parameter = param
-	}
-	jsnErr.Source = errSource
-	ct, encErr := json.Marshal(apherror.HTTPError{Errors: []apherror.Error{jsnErr}})
-	if encErr != nil {
-		return "", http.StatusInternalServerError, encErr
-	}
-	return string(ct), status, nil
-}
-
 func internalServerError(w http.ResponseWriter, msg string) (string, error) {
 	txt := http.StatusText(http.StatusInternalServerError)
 	err := apherror.Errhttp.NewClass(
 		txt,
 		errhttp.SetStatusCode(http.StatusInternalServerError),
 	)
-	err.MustAddData(titleErrKey, txt)
-	str, _, errn := JSONAPIError(err.New(msg))
+	err.MustAddData(shared.TitleErrKey, txt)
+	str, _, errn := shared.JSONAPIError(err.New(msg))
 	w.WriteHeader(http.StatusInternalServerError)
 	return str, errn
 }
@@ -256,12 +295,36 @@ func httpError(w http.ResponseWriter, code int, msg string) (string, error) {
 		http.StatusText(code),
 		errhttp.SetStatusCode(code),
 	)
-	err.MustAddData(titleErrKey, "http error")
-	str, _, errn := JSONAPIError(err.New(msg))
+	err.MustAddData(shared.TitleErrKey, "http error")
+	str, _, errn := shared.JSONAPIError(err.New(msg))
 	w.WriteHeader(code)
 	return str, errn
 }
 
+// invalidGFF3Error reports the invalid lines of a gff3 file
+func invalidGFF3Error(w http.ResponseWriter, rep *gffReport) (string, error) {
+	title := "invalid gff3 file"
+	err := apherror.Errhttp.NewClass(
+		title,
+		errhttp.SetStatusCode(http.StatusUnprocessableEntity),
+	)
+	err.MustAddData(shared.TitleErrKey, title)
+	str, _, errn := shared.JSONAPIError(err.NewWith(
+		fmt.Sprintf("%d of %d lines are invalid", rep.Skipped, rep.Lines),
+		errors.SetData(
+			shared.MetaErrKey,
+			map[string]interface{}{
+				"lines":    rep.Lines,
+				"skipped":  rep.Skipped,
+				"errors":   rep.Errors,
+				"warnings": rep.Warnings,
+			},
+		),
+	))
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	return str, errn
+}
+
 func setRequiredHeaders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/vnd.api+json")
 	// cors headers
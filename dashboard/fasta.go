@@ -0,0 +1,154 @@
+package kubeless
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+)
+
+const (
+	// number of bases in a stored piece of a sequence
+	seqChunkSize = 10000
+	// number of pieces stored in one command
+	seqChunkBatch = 100
+)
+
+// GFF3FastaConsumer stores the sequences of a FASTA file, or of the FASTA
+// section of a gff3 file, by their seqid, the first word of the header.
+// Every sequence is stored in pieces of seqChunkSize bases along with its
+// length, so that any part of it could be fetched without the rest.
+func GFF3FastaConsumer(st shared.Storage, key string, in <-chan string) (<-chan error, error) {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		var seqid string
+		var buf []byte
+		var length, chunk int
+		chunks := make(map[string]string)
+		// store the full pieces, all stores the remaining bases and the
+		// length as well
+		store := func(all bool) error {
+			for len(buf) >= seqChunkSize || (all && len(buf) > 0) {
+				n := seqChunkSize
+				if len(buf) < n {
+					n = len(buf)
+				}
+				chunks[strconv.Itoa(chunk)] = string(buf[:n])
+				buf = append(buf[:0], buf[n:]...)
+				chunk++
+			}
+			if len(chunks) >= seqChunkBatch || (all && len(chunks) > 0) {
+				if err := st.SetMany(sequenceKey(key, seqid), chunks); err != nil {
+					return fmt.Errorf("error in storing sequence of %s %s", seqid, err)
+				}
+				chunks = make(map[string]string)
+			}
+			if !all {
+				return nil
+			}
+			if err := st.Set(seqLengthKey(key), seqid, strconv.Itoa(length)); err != nil {
+				return fmt.Errorf("error in storing length of %s %s", seqid, err)
+			}
+			return nil
+		}
+		for line := range in {
+			line = strings.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			var err error
+			if strings.HasPrefix(line, ">") {
+				if len(seqid) > 0 {
+					err = store(true)
+				}
+				seqid = ""
+				if f := strings.Fields(line[1:]); len(f) > 0 {
+					seqid = f[0]
+				}
+				buf = buf[:0]
+				length, chunk = 0, 0
+			} else if len(seqid) > 0 {
+				buf = append(buf, line...)
+				length += len(line)
+				err = store(false)
+			}
+			if err != nil {
+				errc <- err
+				// the splitter blocks until every line is consumed
+				for range in {
+				}
+				return
+			}
+		}
+		if len(seqid) > 0 {
+			if err := store(true); err != nil {
+				errc <- err
+			}
+		}
+	}()
+	return errc, nil
+}
+
+// storeFasta stores the sequences of a FASTA file
+func storeFasta(r io.Reader, st shared.Storage, key string) error {
+	linec, errc, err := GFF3LineProducer(r)
+	if err != nil {
+		return fmt.Errorf("unable to create fasta producer %s", err)
+	}
+	serrc, err := GFF3FastaConsumer(st, key, linec)
+	if err != nil {
+		return fmt.Errorf("unable to create fasta consumer %s", err)
+	}
+	return WaitForPipeline(errc, serrc)
+}
+
+// getSequence fetches the bases from start to end, both inclusive and
+// starting from 1, of the stored sequence
+func getSequence(st shared.Storage, key, seqid string, start, end int) (string, error) {
+	first := (start - 1) / seqChunkSize
+	last := (end - 1) / seqChunkSize
+	fields := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		fields = append(fields, strconv.Itoa(i))
+	}
+	chunks, err := st.GetMany(sequenceKey(key, seqid), fields...)
+	if err != nil {
+		return "", err
+	}
+	seq := strings.Join(chunks, "")
+	offset := first * seqChunkSize
+	if end-offset > len(seq) {
+		return "", fmt.Errorf("sequence of %s is shorter than %d", seqid, end)
+	}
+	return seq[start-1-offset : end-offset], nil
+}
+
+// sequenceLength returns the length of the stored sequence, false is
+// returned if there is no sequence
+func sequenceLength(st shared.Storage, key, seqid string) (int, bool, error) {
+	v, err := st.Get(seqLengthKey(key), seqid)
+	if shared.IsNotFound(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	l, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid length %s of %s", v, seqid)
+	}
+	return l, true, nil
+}
+
+// sequenceKey is the hash of the pieces of a sequence by their position
+func sequenceKey(key, seqid string) string {
+	return fmt.Sprintf("%s/sequence/%s", key, seqid)
+}
+
+// seqLengthKey is the hash of the length of every sequence
+func seqLengthKey(key string) string {
+	return fmt.Sprintf("%s/sequence", key)
+}
@@ -0,0 +1,274 @@
+package kubeless
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"github.com/go-chi/chi"
+)
+
+// number of features stored in one command
+const featureBatchSize = 1000
+
+type featEntryJsonAPI struct {
+	Data *featEntry `json:"data"`
+}
+
+type featEntryListJsonAPI struct {
+	Data  []*featEntry      `json:"data"`
+	Links map[string]string `json:"links"`
+}
+
+// featEntry is a feature along with its parents and children
+type featEntry struct {
+	Type          string                       `json:"type"`
+	Id            string                       `json:"id"`
+	Attributes    *feature                     `json:"attributes"`
+	Relationships map[string]*featRelationship `json:"relationships"`
+	Links         map[string]string            `json:"links"`
+}
+
+type featRelationship struct {
+	Data  []*featRef        `json:"data"`
+	Links map[string]string `json:"links"`
+}
+
+// featRef identifies a related feature
+type featRef struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+// featEntryRecord is a feature in the index by ID along with its parents
+// and children
+type featEntryRecord struct {
+	Type       string     `json:"type"`
+	Id         string     `json:"id"`
+	Attributes *feature   `json:"attributes"`
+	Parents    []*featRef `json:"parents,omitempty"`
+	Children   []*featRef `json:"children,omitempty"`
+}
+
+// GFF3FeatureConsumer stores every feature by its ID, the children of a
+// feature are the ones that name it in their Parent attribute
+func GFF3FeatureConsumer(st shared.Storage, key string, in <-chan string) (<-chan error, error) {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		entries := make(map[string]*featEntryRecord)
+		parents := make(map[string][]string)
+		fids := make(featIds)
+		var ids []string
+		for line := range in {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			s := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+			if len(s) < 9 {
+				continue
+			}
+			fd, err := newFeatData(s, fids)
+			if err != nil || len(fd.Id) == 0 {
+				continue
+			}
+			// the validator leaves out the lines that use the ID of
+			// another feature, so the first one is kept if any gets here
+			if e, ok := entries[fd.Id]; ok {
+				if e.Type == fd.Type {
+					addSegment(e.Attributes, fd.Attributes)
+				} else {
+					log.Printf("feature %s of type %s is already a %s", fd.Id, fd.Type, e.Type)
+				}
+				continue
+			}
+			ids = append(ids, fd.Id)
+			entries[fd.Id] = &featEntryRecord{
+				Type:       fd.Type,
+				Id:         fd.Id,
+				Attributes: fd.Attributes,
+			}
+			parents[fd.Id] = fd.Attributes.GFFAttributes["Parent"]
+		}
+		// parents missing from the file are left out
+		for _, id := range ids {
+			e := entries[id]
+			for _, p := range parents[id] {
+				pe, ok := entries[p]
+				if !ok {
+					continue
+				}
+				e.Parents = append(e.Parents, &featRef{Type: pe.Type, Id: pe.Id})
+				pe.Children = append(pe.Children, &featRef{Type: e.Type, Id: e.Id})
+			}
+		}
+		values := make(map[string]string)
+		for i, id := range ids {
+			b, err := json.Marshal(entries[id])
+			if err != nil {
+				errc <- fmt.Errorf("error in json encoding %s", err)
+				return
+			}
+			values[id] = string(b)
+			if len(values) < featureBatchSize && i < len(ids)-1 {
+				continue
+			}
+			if err := st.SetMany(featureKey(key), values); err != nil {
+				errc <- fmt.Errorf("error in storing features %s", err)
+				return
+			}
+			values = make(map[string]string)
+		}
+	}()
+	return errc, nil
+}
+
+// addSegment adds the location of a line with the same ID as the
+// feature, the feature then spans all of its segments
+func addSegment(feat, seg *feature) {
+	if len(feat.Segments) == 0 {
+		feat.Segments = []*featSegment{
+			{Start: feat.Start, End: feat.End, Phase: feat.Phase},
+		}
+	}
+	feat.Segments = append(feat.Segments, &featSegment{
+		Start: seg.Start,
+		End:   seg.End,
+		Phase: seg.Phase,
+	})
+	if seg.Start < feat.Start {
+		feat.Start = seg.Start
+	}
+	if seg.End > feat.End {
+		feat.End = seg.End
+	}
+}
+
+// featureHandler returns the feature with the given ID
+func featureHandler(st shared.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taxonid := chi.URLParam(r, "taxonid")
+		key := fmt.Sprintf("%s-%s", KEY_PREFIX, taxonid)
+		rec, status, err := getFeature(st, key, chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		ct, err := json.Marshal(&featEntryJsonAPI{
+			Data: newFeatEntry(r, taxonid, rec),
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error in json encoding %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%s", ct)
+	}
+}
+
+// relatedHandler returns the parents or the children of the feature with
+// the given ID
+func relatedHandler(st shared.Storage, rel string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taxonid := chi.URLParam(r, "taxonid")
+		key := fmt.Sprintf("%s-%s", KEY_PREFIX, taxonid)
+		id := chi.URLParam(r, "id")
+		rec, status, err := getFeature(st, key, id)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		refs := rec.Children
+		if rel == "parents" {
+			refs = rec.Parents
+		}
+		featData := make([]*featEntry, 0)
+		for _, ref := range refs {
+			frec, status, err := getFeature(st, key, ref.Id)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+			featData = append(featData, newFeatEntry(r, taxonid, frec))
+		}
+		ct, err := json.Marshal(&featEntryListJsonAPI{
+			Data: featData,
+			Links: map[string]string{
+				"self": fmt.Sprintf("%s/%s", featureLink(r, taxonid, id), rel),
+			},
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error in json encoding %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%s", ct)
+	}
+}
+
+// getFeature fetches the feature with the given ID, the http status code
+// is returned along with any error
+func getFeature(st shared.Storage, key, id string) (*featEntryRecord, int, error) {
+	v, err := st.Get(featureKey(key), id)
+	if shared.IsNotFound(err) {
+		return nil, http.StatusNotFound, fmt.Errorf("feature %s does not exist", id)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error %s in retrieving %s", err, id)
+	}
+	rec := &featEntryRecord{}
+	if err := json.Unmarshal([]byte(v), rec); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("error in decoding feature %s", err)
+	}
+	return rec, http.StatusOK, nil
+}
+
+// newFeatEntry makes the JSON:API resource of the feature
+func newFeatEntry(r *http.Request, taxonid string, rec *featEntryRecord) *featEntry {
+	link := featureLink(r, taxonid, rec.Id)
+	rels := make(map[string]*featRelationship)
+	for rel, refs := range map[string][]*featRef{
+		"parents":  rec.Parents,
+		"children": rec.Children,
+	} {
+		if refs == nil {
+			refs = make([]*featRef, 0)
+		}
+		rels[rel] = &featRelationship{
+			Data: refs,
+			Links: map[string]string{
+				"related": fmt.Sprintf("%s/%s", link, rel),
+			},
+		}
+	}
+	return &featEntry{
+		Type:          rec.Type,
+		Id:            rec.Id,
+		Attributes:    rec.Attributes,
+		Relationships: rels,
+		Links:         map[string]string{"self": link},
+	}
+}
+
+// featureLink makes the link of a feature from any request proxied to the
+// api server
+func featureLink(r *http.Request, taxonid, id string) string {
+	path := r.Header.Get("X-Original-Uri")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return fmt.Sprintf(
+		"%s://%s%s/genomes/%s/features/%s",
+		r.Header.Get("X-Forwarded-Proto"),
+		r.Host,
+		strings.TrimSuffix(path, r.URL.Path),
+		taxonid,
+		id,
+	)
+}
+
+// featureKey is the hash of the features by their ID
+func featureKey(key string) string {
+	return fmt.Sprintf("%s/features", key)
+}
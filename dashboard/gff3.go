@@ -8,9 +8,18 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/dictybase-playground/kubeless-gofn/shared"
 )
 
-type GFF3Consumer func(Storage, string, string, <-chan string) (<-chan error, error)
+type GFF3Consumer func(shared.Storage, string, string, <-chan string) (<-chan error, error)
+
+const (
+	// number of features added to the index in one command
+	indexBatchSize = 1000
+	// maximum length of a line of a gff3 or FASTA file
+	maxLineSize = 64 * 1024 * 1024
+)
 
 var rmap = map[string]GFF3Consumer{
 	"chromosome":  GFF3RegionConsumer,
@@ -28,11 +37,12 @@ type chrdata struct {
 }
 
 type chromosome struct {
-	Name   string `json:"name"`
-	Id     string `json:"id"`
-	Length int    `json:"length"`
-	Start  int    `json:"start"`
-	End    int    `json:"end"`
+	Name          string        `json:"name"`
+	Id            string        `json:"id"`
+	Length        int           `json:"length"`
+	Start         int           `json:"start"`
+	End           int           `json:"end"`
+	GFFAttributes gffAttributes `json:"gff_attributes,omitempty"`
 }
 
 type featJsonAPI struct {
@@ -46,16 +56,35 @@ type featdata struct {
 }
 
 type feature struct {
-	SeqId   string `json:"seqid"`
-	BlockId string `json:"block_id"`
-	Source  string `json:"source"`
-	Start   int    `json:"start"`
-	End     int    `json:"end"`
-	Strand  string `json:"strand"`
+	SeqId         string        `json:"seqid"`
+	BlockId       string        `json:"block_id"`
+	Source        string        `json:"source"`
+	Start         int           `json:"start"`
+	End           int           `json:"end"`
+	Strand        string        `json:"strand"`
+	Phase         string        `json:"phase,omitempty"`
+	Name          string        `json:"name,omitempty"`
+	GFFAttributes gffAttributes `json:"gff_attributes,omitempty"`
+	// Segments are the parts of a feature given by multiple lines with
+	// the same ID, such as a CDS split by introns
+	Segments []*featSegment `json:"segments,omitempty"`
+}
+
+type featSegment struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Phase string `json:"phase,omitempty"`
 }
 
-func storeGFFInforamtion(r io.Reader, st Storage, key string, ftypes ...string) error {
+// storeGFFInforamtion stores the features of the given types along with
+// the indexes of all features, the invalid lines are skipped and added to
+// the report
+func storeGFFInforamtion(r io.Reader, st shared.Storage, key string, rep *gffReport, ftypes ...string) error {
 	var errcList []<-chan error
+	// remove the index of the previous upload
+	if _, err := st.ClearAll(key + "/"); err != nil {
+		return fmt.Errorf("unable to remove feature index %s", err)
+	}
 	// Read GFF3 and sends the lines in the channel
 	linec, errc, err := GFF3LineProducer(r)
 	if err != nil {
@@ -63,12 +92,48 @@ func storeGFFInforamtion(r io.Reader, st Storage, key string, ftypes ...string)
 	}
 	errcList = append(errcList, errc)
 
-	// Read GFF3 line and fan out to multiple consumer channels
-	allc, errc, err := GFF3Splitter(linec, len(ftypes))
+	// Separate the embedded sequences from the features
+	featc, fastac, errc, err := GFF3SectionSplitter(linec)
+	if err != nil {
+		return fmt.Errorf("unable to create gff3 section splitter %s", err)
+	}
+	errcList = append(errcList, errc)
+	errc, err = GFF3FastaConsumer(st, key, fastac)
+	if err != nil {
+		return fmt.Errorf("unable to create fasta consumer %s", err)
+	}
+	errcList = append(errcList, errc)
+
+	// Leave out the invalid lines
+	linec, errc, err = GFF3LineValidator(featc, rep)
+	if err != nil {
+		return fmt.Errorf("unable to create gff3 validator %s", err)
+	}
+	errcList = append(errcList, errc)
+
+	// Read GFF3 line and fan out to multiple consumer channels, the
+	// last three are for indexing all features by region and by ID and
+	// for the sequence regions
+	allc, errc, err := GFF3Splitter(linec, len(ftypes)+3)
 	if err != nil {
 		return fmt.Errorf("unable to create gff3 splitter %s", err)
 	}
 	errcList = append(errcList, errc)
+	errc, err = GFF3IndexConsumer(st, key, allc[len(ftypes)])
+	if err != nil {
+		return fmt.Errorf("unable to create index consumer %s", err)
+	}
+	errcList = append(errcList, errc)
+	errc, err = GFF3FeatureConsumer(st, key, allc[len(ftypes)+1])
+	if err != nil {
+		return fmt.Errorf("unable to create feature consumer %s", err)
+	}
+	errcList = append(errcList, errc)
+	errc, err = GFF3SequenceRegionConsumer(st, key, allc[len(ftypes)+2])
+	if err != nil {
+		return fmt.Errorf("unable to create sequence region consumer %s", err)
+	}
+	errcList = append(errcList, errc)
 
 	// Now the GFF3 consumer receives and extract lines
 	for i, t := range ftypes {
@@ -81,7 +146,7 @@ func storeGFFInforamtion(r io.Reader, st Storage, key string, ftypes ...string)
 		} else {
 			errc, err := GFF3GenericConsumer(st, key, t, allc[i])
 			if err != nil {
-				fmt.Errorf("unable to create consumer for %s %s", t, err)
+				return fmt.Errorf("unable to create consumer for %s %s", t, err)
 			}
 			errcList = append(errcList, errc)
 		}
@@ -96,6 +161,8 @@ func GFF3LineProducer(r io.Reader) (<-chan string, <-chan error, error) {
 		defer close(out)
 		defer close(errc)
 		scanner := bufio.NewScanner(r)
+		// a FASTA sequence could be in a single line
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
 		for scanner.Scan() {
 			line := fmt.Sprintf("%s\n", scanner.Text())
 			out <- line
@@ -128,44 +195,163 @@ func GFF3Splitter(in <-chan string, len int) ([]chan string, <-chan error, error
 	return out, errc, nil
 }
 
-func GFF3RegionConsumer(st Storage, key, t string, in <-chan string) (<-chan error, error) {
+// GFF3SectionSplitter sends the lines before the ##FASTA directive to the
+// first channel and the ones after it to the second
+func GFF3SectionSplitter(in <-chan string) (<-chan string, <-chan string, <-chan error, error) {
+	featc := make(chan string)
+	fastac := make(chan string)
 	errc := make(chan error, 1)
 	go func() {
+		defer close(featc)
+		defer close(fastac)
 		defer close(errc)
-		seenFasta := false
-		var chrData []*chrdata
+		out := featc
 		for line := range in {
-			if seenFasta {
+			if out == featc && strings.HasPrefix(line, "##FASTA") {
+				out = fastac
 				continue
 			}
-			if strings.HasPrefix(line, "##") {
-				if strings.HasPrefix(line, "###") {
-					seenFasta = true
+			out <- line
+		}
+	}()
+	return featc, fastac, errc, nil
+}
+
+// sequenceRegion is the reference sequence given by a ##sequence-region
+// directive
+type sequenceRegion struct {
+	SeqId string
+	Start int
+	End   int
+}
+
+// parseSequenceRegion parses the ##sequence-region seqid start end
+// directive
+func parseSequenceRegion(line string) (*sequenceRegion, error) {
+	s := strings.Fields(line)
+	if len(s) != 4 {
+		return nil, fmt.Errorf("expected seqid, start and end in %s", s[0])
+	}
+	start, err := strconv.Atoi(s[2])
+	if err != nil || start < 1 {
+		return nil, fmt.Errorf("start %s of %s is not a positive integer", s[2], s[1])
+	}
+	end, err := strconv.Atoi(s[3])
+	if err != nil || end < 1 {
+		return nil, fmt.Errorf("end %s of %s is not a positive integer", s[3], s[1])
+	}
+	if start > end {
+		return nil, fmt.Errorf("start %d of %s is greater than end %d", start, s[1], end)
+	}
+	return &sequenceRegion{SeqId: s[1], Start: start, End: end}, nil
+}
+
+func (sr *sequenceRegion) length() int {
+	return sr.End - sr.Start + 1
+}
+
+type seqRegionJsonAPI struct {
+	Data []*seqRegionData `json:"data"`
+}
+
+type seqRegionData struct {
+	Type       string          `json:"type"`
+	Id         string          `json:"id"`
+	Attributes *seqRegionAttrs `json:"attributes"`
+}
+
+type seqRegionAttrs struct {
+	SeqId  string `json:"seqid"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Length int    `json:"length"`
+}
+
+// GFF3SequenceRegionConsumer stores the reference sequences declared by
+// the ##sequence-region directives
+func GFF3SequenceRegionConsumer(st shared.Storage, key string, in <-chan string) (<-chan error, error) {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		regionData := make([]*seqRegionData, 0)
+		for line := range in {
+			if !strings.HasPrefix(line, "##sequence-region") {
+				continue
+			}
+			sr, err := parseSequenceRegion(line)
+			if err != nil {
+				continue
+			}
+			regionData = append(regionData, &seqRegionData{
+				Type: "sequence-regions",
+				Id:   sr.SeqId,
+				Attributes: &seqRegionAttrs{
+					SeqId:  sr.SeqId,
+					Start:  sr.Start,
+					End:    sr.End,
+					Length: sr.length(),
+				},
+			})
+		}
+		ct, err := json.Marshal(&seqRegionJsonAPI{Data: regionData})
+		if err != nil {
+			errc <- fmt.Errorf("error in json encoding %s", err)
+			return
+		}
+		if err := st.Set(key, "sequence-regions", string(ct)); err != nil {
+			errc <- fmt.Errorf("error in storing sequence regions %s", err)
+		}
+	}()
+	return errc, nil
+}
+
+func GFF3RegionConsumer(st shared.Storage, key, t string, in <-chan string) (<-chan error, error) {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		var chrData []*chrdata
+		var seqids []string
+		regions := make(map[string]*sequenceRegion)
+		for line := range in {
+			if strings.HasPrefix(line, "##sequence-region") {
+				if sr, err := parseSequenceRegion(line); err == nil {
+					regions[sr.SeqId] = sr
 				}
 				continue
 			}
-			s := strings.Split(line, "\t")
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			s := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
 			if s[2] != t {
 				continue
 			}
+			attrs, err := parseAttributes(s[8])
+			if err != nil {
+				continue
+			}
 			start, _ := strconv.Atoi(s[3])
 			end, _ := strconv.Atoi(s[4])
 			chr := &chromosome{
-				Start:  start,
-				End:    end,
-				Length: end - start,
+				Id:            attrs.first("ID"),
+				Name:          attrs.first("Name"),
+				Start:         start,
+				End:           end,
+				Length:        end - start + 1,
+				GFFAttributes: attrs,
 			}
-			fields := strings.Split(s[8], ";")
-			chr.Id = strings.Split(fields[0], "=")[1]
-			chr.Name = strings.TrimSuffix(
-				strings.Split(fields[1], "=")[1],
-				"\n",
-			)
 			chrData = append(chrData, &chrdata{
 				Type:       fmt.Sprintf("%ss", t),
 				Id:         chr.Id,
 				Attributes: chr,
 			})
+			seqids = append(seqids, s[0])
+		}
+		// the declared sequence region gives the length
+		for i, cd := range chrData {
+			if sr, ok := regions[seqids[i]]; ok {
+				cd.Attributes.Length = sr.length()
+			}
 		}
 		ct, err := json.Marshal(&chrJsonAPI{Data: chrData})
 		if err != nil {
@@ -178,57 +364,163 @@ func GFF3RegionConsumer(st Storage, key, t string, in <-chan string) (<-chan err
 	return errc, nil
 }
 
-func GFF3GenericConsumer(st Storage, key, t string, in <-chan string) (<-chan error, error) {
+// GFF3GenericConsumer stores the list of the features of type t, the lines
+// with the same ID are listed as one feature spanning all of them
+func GFF3GenericConsumer(st shared.Storage, key, t string, in <-chan string) (<-chan error, error) {
 	errc := make(chan error, 1)
 	go func() {
 		defer close(errc)
 		var featData []*featdata
-		seenFasta := false
+		seen := make(map[string]*featdata)
+		ids := make(featIds)
 		for line := range in {
-			if seenFasta {
-				continue
-			}
-			if strings.HasPrefix(line, "##") {
-				if strings.HasPrefix(line, "###") {
-					seenFasta = true
-				}
+			if strings.HasPrefix(line, "#") {
 				continue
 			}
-			s := strings.Split(line, "\t")
+			s := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
 			if t != s[2] {
 				continue
 			}
-			fields := strings.Split(s[8], ";")
-			Id := strings.Split(fields[0], "=")[1]
-			start, _ := strconv.Atoi(s[3])
-			end, _ := strconv.Atoi(s[4])
-			feat := &feature{
-				SeqId:   s[0],
-				BlockId: s[0],
-				Start:   start,
-				End:     end,
-				Strand:  s[6],
-			}
-			if len(s[1]) > 0 {
-				feat.Source = s[1]
+			fd, err := newFeatData(s, ids)
+			if err != nil {
+				continue
 			}
-			featData = append(featData, &featdata{
-				Type:       fmt.Sprintf("%ss", t),
-				Id:         Id,
-				Attributes: feat,
-			})
+			featData = appendFeature(featData, seen, fd)
 		}
 		ct, err := json.Marshal(&featJsonAPI{Data: featData})
 		if err != nil {
 			errc <- fmt.Errorf("error in json encoding %s", err)
 		}
 		if err := st.Set(key, fmt.Sprintf("%ss", t), string(ct)); err != nil {
-			errc <- fmt.Errorf("error in storing %t data %s", t, err)
+			errc <- fmt.Errorf("error in storing %s data %s", t, err)
 		}
 	}()
 	return errc, nil
 }
 
+// GFF3IndexConsumer indexes the features of every type other than the
+// reference sequences by their reference sequence and start, so that the
+// ones overlapping a region could be looked up. The lines with the same ID
+// are indexed as one feature spanning all of them.
+func GFF3IndexConsumer(st shared.Storage, key string, in <-chan string) (<-chan error, error) {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		var feats []*featdata
+		seen := make(map[string]*featdata)
+		ids := make(featIds)
+		for line := range in {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			s := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+			if len(s) < 9 {
+				continue
+			}
+			// the reference sequences span the whole region
+			if _, ok := rmap[s[2]]; ok {
+				continue
+			}
+			fd, err := newFeatData(s, ids)
+			if err != nil {
+				continue
+			}
+			feats = appendFeature(feats, seen, fd)
+		}
+		members := make(map[string][]shared.SortedMember)
+		maxLen := make(map[string]int)
+		for _, fd := range feats {
+			b, err := json.Marshal(fd)
+			if err != nil {
+				errc <- fmt.Errorf("error in json encoding %s", err)
+				return
+			}
+			seqid := fd.Attributes.SeqId
+			members[seqid] = append(members[seqid], shared.SortedMember{
+				Score:  float64(fd.Attributes.Start),
+				Member: string(b),
+			})
+			if l := fd.Attributes.End - fd.Attributes.Start + 1; l > maxLen[seqid] {
+				maxLen[seqid] = l
+			}
+		}
+		for seqid, m := range members {
+			for i := 0; i < len(m); i += indexBatchSize {
+				j := i + indexBatchSize
+				if j > len(m) {
+					j = len(m)
+				}
+				if err := st.AddSorted(indexKey(key, seqid), m[i:j]...); err != nil {
+					errc <- fmt.Errorf("error in indexing features of %s %s", seqid, err)
+					return
+				}
+			}
+			err := st.Set(maxLenKey(key), seqid, strconv.Itoa(maxLen[seqid]))
+			if err != nil {
+				errc <- fmt.Errorf("error in storing length of %s %s", seqid, err)
+				return
+			}
+		}
+	}()
+	return errc, nil
+}
+
+// newFeatData makes the feature from the columns of a gff3 line, ids
+// gives the ID of the feature
+func newFeatData(s []string, ids featIds) (*featdata, error) {
+	attrs, err := parseAttributes(s[8])
+	if err != nil {
+		return nil, err
+	}
+	start, _ := strconv.Atoi(s[3])
+	end, _ := strconv.Atoi(s[4])
+	feat := &feature{
+		SeqId:         s[0],
+		BlockId:       s[0],
+		Start:         start,
+		End:           end,
+		Strand:        s[6],
+		Name:          attrs.first("Name"),
+		GFFAttributes: attrs,
+	}
+	if len(s[1]) > 0 {
+		feat.Source = s[1]
+	}
+	if s[7] != "." {
+		feat.Phase = s[7]
+	}
+	return &featdata{
+		Type:       fmt.Sprintf("%ss", s[2]),
+		Id:         ids.id(s[2], attrs),
+		Attributes: feat,
+	}, nil
+}
+
+// appendFeature appends the feature unless an earlier one has the same ID
+// and type, the feature is then added as a segment of that one
+func appendFeature(feats []*featdata, seen map[string]*featdata, fd *featdata) []*featdata {
+	if len(fd.Id) == 0 {
+		return append(feats, fd)
+	}
+	if e, ok := seen[fd.Id]; ok && e.Type == fd.Type {
+		addSegment(e.Attributes, fd.Attributes)
+		return feats
+	}
+	seen[fd.Id] = fd
+	return append(feats, fd)
+}
+
+// indexKey is the sorted set of the features of a reference sequence
+func indexKey(key, seqid string) string {
+	return fmt.Sprintf("%s/index/%s", key, seqid)
+}
+
+// maxLenKey is the hash with the length of the longest feature of every
+// reference sequence
+func maxLenKey(key string) string {
+	return fmt.Sprintf("%s/index", key)
+}
+
 // WaitForPipeline waits for results from all error channels.
 // It returns early on the first error.
 func WaitForPipeline(errs ...<-chan error) error {
@@ -4,9 +4,9 @@ go 1.13
 
 require (
 	github.com/dictyBase/apihelpers v0.0.0-20180801151846-aa9d10182786
+	github.com/dictybase-playground/kubeless-gofn/shared v0.0.0
 	github.com/go-chi/chi v3.3.2+incompatible
 	github.com/go-ini/ini v1.38.1 // indirect
-	github.com/go-redis/redis v6.13.2+incompatible
 	github.com/kubeless/kubeless v1.0.7
 	github.com/minio/minio-go v6.0.5+incompatible
 	github.com/mitchellh/go-homedir v0.0.0-20180801233206-58046073cbff // indirect
@@ -15,3 +15,5 @@ require (
 	github.com/spacemonkeygo/errors v0.0.0-20171212215202-9064522e9fd1
 	gopkg.in/ini.v1 v1.57.0 // indirect
 )
+
+replace github.com/dictybase-playground/kubeless-gofn/shared => ../shared
@@ -0,0 +1,100 @@
+package kubeless
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"github.com/go-chi/chi"
+)
+
+// regionHandler returns the features of a reference sequence that overlap
+// the region given by the start and end query parameters, optionally
+// limited to the type parameter
+func regionHandler(st shared.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := fmt.Sprintf("%s-%s", KEY_PREFIX, chi.URLParam(r, "taxonid"))
+		seqid := chi.URLParam(r, "seqid")
+		start, err := coordParam(r, "start", 1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		end, err := coordParam(r, "end", math.MaxInt32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start > end {
+			http.Error(
+				w,
+				fmt.Sprintf("start %d is greater than end %d", start, end),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		v, err := st.Get(maxLenKey(key), seqid)
+		if shared.IsNotFound(err) {
+			http.Error(w, fmt.Sprintf("no features for %s", seqid), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error %s in retrieving %s", err, seqid), http.StatusInternalServerError)
+			return
+		}
+		maxLen, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid length %s of %s", v, seqid), http.StatusInternalServerError)
+			return
+		}
+		// features starting before this could not reach the region
+		members, err := st.RangeSorted(
+			indexKey(key, seqid),
+			float64(start-maxLen+1),
+			float64(end),
+		)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error %s in retrieving features of %s", err, seqid), http.StatusInternalServerError)
+			return
+		}
+		ftype := r.URL.Query().Get("type")
+		featData := make([]*featdata, 0)
+		for _, m := range members {
+			fd := &featdata{}
+			if err := json.Unmarshal([]byte(m), fd); err != nil {
+				http.Error(w, fmt.Sprintf("error in decoding feature %s", err), http.StatusInternalServerError)
+				return
+			}
+			if fd.Attributes.End < start {
+				continue
+			}
+			if len(ftype) > 0 && fd.Type != fmt.Sprintf("%ss", ftype) {
+				continue
+			}
+			featData = append(featData, fd)
+		}
+		ct, err := json.Marshal(&featJsonAPI{Data: featData})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error in json encoding %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%s", ct)
+	}
+}
+
+// coordParam returns the positive integer of the query parameter, def is
+// returned if it is absent
+func coordParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if len(v) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s has to be a positive integer, got %s", name, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,394 @@
+package kubeless
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"github.com/go-chi/chi"
+)
+
+type seqJsonAPI struct {
+	Data *seqData `json:"data"`
+}
+
+type seqData struct {
+	Type       string    `json:"type"`
+	Id         string    `json:"id"`
+	Attributes *sequence `json:"attributes"`
+}
+
+type sequence struct {
+	SeqId    string `json:"seqid"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Strand   string `json:"strand"`
+	Length   int    `json:"length"`
+	Sequence string `json:"sequence"`
+}
+
+type featSeqJsonAPI struct {
+	Data *featSeqData `json:"data"`
+}
+
+type featSeqData struct {
+	Type       string        `json:"type"`
+	Id         string        `json:"id"`
+	Attributes *featSeqAttrs `json:"attributes"`
+}
+
+type featSeqAttrs struct {
+	FeatureId string `json:"feature_id"`
+	SeqType   string `json:"sequence_type"`
+	// TranscriptId is the descendant of the feature, such as the first
+	// transcript of a gene, whose exons or CDSs are used
+	TranscriptId string `json:"transcript_id,omitempty"`
+	SeqId        string `json:"seqid"`
+	Strand       string `json:"strand"`
+	Table        int    `json:"translation_table,omitempty"`
+	Length       int    `json:"length"`
+	Sequence     string `json:"sequence"`
+}
+
+// levels of the hierarchy below a feature that are searched for its exons
+// or CDSs, gene > transcript > exon
+const maxSegmentDepth = 2
+
+// complements of the IUPAC nucleotide codes
+var complement = map[byte]byte{
+	'A': 'T', 'T': 'A', 'G': 'C', 'C': 'G', 'U': 'A',
+	'R': 'Y', 'Y': 'R', 'K': 'M', 'M': 'K', 'S': 'S', 'W': 'W',
+	'B': 'V', 'V': 'B', 'D': 'H', 'H': 'D', 'N': 'N',
+	'a': 't', 't': 'a', 'g': 'c', 'c': 'g', 'u': 'a',
+	'r': 'y', 'y': 'r', 'k': 'm', 'm': 'k', 's': 's', 'w': 'w',
+	'b': 'v', 'v': 'b', 'd': 'h', 'h': 'd', 'n': 'n',
+}
+
+// sequenceHandler returns the part of a reference sequence given by the
+// start, end and strand query parameters
+func sequenceHandler(st shared.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := fmt.Sprintf("%s-%s", KEY_PREFIX, chi.URLParam(r, "taxonid"))
+		seqid := chi.URLParam(r, "seqid")
+		length, ok, err := sequenceLength(st, key, seqid)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error %s in retrieving %s", err, seqid), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, fmt.Sprintf("no sequence for %s", seqid), http.StatusNotFound)
+			return
+		}
+		start, err := coordParam(r, "start", 1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		end, err := coordParam(r, "end", length)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if start > end {
+			http.Error(
+				w,
+				fmt.Sprintf("start %d is greater than end %d", start, end),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		if end > length {
+			http.Error(
+				w,
+				fmt.Sprintf("end %d is beyond the length %d of %s", end, length, seqid),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		strand, err := strandParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		seq, err := getSequence(st, key, seqid, start, end)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error %s in retrieving sequence of %s", err, seqid), http.StatusInternalServerError)
+			return
+		}
+		if strand == "-" {
+			seq = reverseComplement(seq)
+		}
+		ct, err := json.Marshal(&seqJsonAPI{
+			Data: &seqData{
+				Type: "sequences",
+				Id:   fmt.Sprintf("%s:%d..%d:%s", seqid, start, end, strand),
+				Attributes: &sequence{
+					SeqId:    seqid,
+					Start:    start,
+					End:      end,
+					Strand:   strand,
+					Length:   len(seq),
+					Sequence: seq,
+				},
+			},
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error in json encoding %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%s", ct)
+	}
+}
+
+// strandParam returns the strand query parameter, it is + if absent. An
+// unescaped + in the query is decoded as a space.
+func strandParam(r *http.Request) (string, error) {
+	v := strings.TrimSpace(r.URL.Query().Get("strand"))
+	switch v {
+	case "", "+":
+		return "+", nil
+	case "-":
+		return "-", nil
+	}
+	return "", fmt.Errorf("strand has to be + or -, got %s", v)
+}
+
+// reverseComplement returns the reverse complement of the sequence, the
+// bases without a complement are kept as they are
+func reverseComplement(seq string) string {
+	b := make([]byte, len(seq))
+	for i := 0; i < len(seq); i++ {
+		c, ok := complement[seq[i]]
+		if !ok {
+			c = seq[i]
+		}
+		b[len(seq)-1-i] = c
+	}
+	return string(b)
+}
+
+// featureSequenceHandler returns the sequence of the feature given by the
+// type query parameter,
+//
+//	genomic - the region of the feature (default)
+//	spliced - the exons of a transcript joined together
+//	cds - the CDS of a transcript joined together, without the bases
+//	before the first codon given by the phase
+//	protein - translation of the cds
+//
+// A feature without exons or CDSs of its own, such as a gene, uses the
+// first of its transcripts that has them.
+func featureSequenceHandler(st shared.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := fmt.Sprintf("%s-%s", KEY_PREFIX, chi.URLParam(r, "taxonid"))
+		rec, status, err := getFeature(st, key, chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		seqType := r.URL.Query().Get("type")
+		if len(seqType) == 0 {
+			seqType = "genomic"
+		}
+		var segType string
+		switch seqType {
+		case "genomic":
+		case "spliced":
+			segType = "exons"
+		case "cds", "protein":
+			segType = "CDSs"
+		default:
+			http.Error(
+				w,
+				fmt.Sprintf("type has to be genomic, spliced, cds or protein, got %s", seqType),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		feat := rec.Attributes
+		length, ok, err := sequenceLength(st, key, feat.SeqId)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error %s in retrieving %s", err, feat.SeqId), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, fmt.Sprintf("no sequence for %s", feat.SeqId), http.StatusNotFound)
+			return
+		}
+		segs := []*featSegment{{Start: feat.Start, End: feat.End}}
+		segsId := rec.Id
+		if len(segType) > 0 {
+			segs = featSegments(feat)
+			if rec.Type != segType {
+				segs, segsId, status, err = childSegments(st, key, rec, segType)
+				if err != nil {
+					http.Error(w, err.Error(), status)
+					return
+				}
+			}
+		}
+		sort.Slice(segs, func(i, j int) bool { return segs[i].Start < segs[j].Start })
+		for _, sg := range segs {
+			if sg.End > length {
+				http.Error(
+					w,
+					fmt.Sprintf("end %d of %s is beyond the length %d of %s", sg.End, rec.Id, length, feat.SeqId),
+					http.StatusBadRequest,
+				)
+				return
+			}
+		}
+		seq, err := joinSegments(st, key, feat.SeqId, feat.Strand, segs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		attrs := &featSeqAttrs{
+			FeatureId: rec.Id,
+			SeqType:   seqType,
+			SeqId:     feat.SeqId,
+			Strand:    feat.Strand,
+		}
+		if segsId != rec.Id {
+			attrs.TranscriptId = segsId
+		}
+		if segType == "CDSs" {
+			seq = trimPhase(seq, feat.Strand, segs)
+		}
+		if seqType == "protein" {
+			attrs.Table, err = translationTable(r, feat.SeqId)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			seq, err = translate(seq, attrs.Table)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		attrs.Sequence = seq
+		attrs.Length = len(seq)
+		ct, err := json.Marshal(&featSeqJsonAPI{
+			Data: &featSeqData{
+				Type:       "sequences",
+				Id:         fmt.Sprintf("%s:%s", rec.Id, seqType),
+				Attributes: attrs,
+			},
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error in json encoding %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%s", ct)
+	}
+}
+
+// featSegments returns the locations of the feature
+func featSegments(feat *feature) []*featSegment {
+	if len(feat.Segments) > 0 {
+		return feat.Segments
+	}
+	return []*featSegment{{Start: feat.Start, End: feat.End, Phase: feat.Phase}}
+}
+
+// childSegments returns the locations of the children of the given type
+// along with the ID of their parent, which is either the feature or the
+// first of its descendants that has such children
+func childSegments(st shared.Storage, key string, rec *featEntryRecord, segType string) ([]*featSegment, string, int, error) {
+	segs, id, status, err := descendantSegments(st, key, rec, segType, maxSegmentDepth)
+	if err != nil {
+		return nil, "", status, err
+	}
+	if len(segs) == 0 {
+		return nil, "", http.StatusNotFound, fmt.Errorf(
+			"%s has no %s", rec.Id, strings.TrimSuffix(segType, "s"),
+		)
+	}
+	return segs, id, http.StatusOK, nil
+}
+
+// descendantSegments looks for the children of the given type down to the
+// given depth, the children of the feature come before the ones of its
+// descendants
+func descendantSegments(st shared.Storage, key string, rec *featEntryRecord, segType string, depth int) ([]*featSegment, string, int, error) {
+	var segs []*featSegment
+	for _, ref := range rec.Children {
+		if ref.Type != segType {
+			continue
+		}
+		child, status, err := getFeature(st, key, ref.Id)
+		if err != nil {
+			return nil, "", status, err
+		}
+		segs = append(segs, featSegments(child.Attributes)...)
+	}
+	if len(segs) > 0 || depth <= 1 {
+		return segs, rec.Id, http.StatusOK, nil
+	}
+	for _, ref := range rec.Children {
+		child, status, err := getFeature(st, key, ref.Id)
+		if err != nil {
+			return nil, "", status, err
+		}
+		dsegs, id, status, err := descendantSegments(st, key, child, segType, depth-1)
+		if err != nil {
+			return nil, "", status, err
+		}
+		if len(dsegs) > 0 {
+			return dsegs, id, http.StatusOK, nil
+		}
+	}
+	return nil, "", http.StatusOK, nil
+}
+
+// joinSegments returns the sequences of the segments joined together, it
+// is reverse complemented for the minus strand
+func joinSegments(st shared.Storage, key, seqid, strand string, segs []*featSegment) (string, error) {
+	var b strings.Builder
+	for _, sg := range segs {
+		seq, err := getSequence(st, key, seqid, sg.Start, sg.End)
+		if err != nil {
+			return "", fmt.Errorf("error %s in retrieving sequence of %s", err, seqid)
+		}
+		b.WriteString(seq)
+	}
+	if strand == "-" {
+		return reverseComplement(b.String()), nil
+	}
+	return b.String(), nil
+}
+
+// trimPhase removes the bases before the first codon, given by the phase
+// of the first segment in the direction of transcription
+func trimPhase(seq, strand string, segs []*featSegment) string {
+	first := segs[0]
+	if strand == "-" {
+		first = segs[len(segs)-1]
+	}
+	phase, err := strconv.Atoi(first.Phase)
+	if err != nil || phase > len(seq) {
+		return seq
+	}
+	return seq[phase:]
+}
+
+// translationTable returns the table query parameter, otherwise the one of
+// the seqid from the environment or else the standard genetic code
+func translationTable(r *http.Request, seqid string) (int, error) {
+	if v := r.URL.Query().Get("table"); len(v) > 0 {
+		t, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("table has to be a number, got %s", v)
+		}
+		return t, nil
+	}
+	if t, ok := seqIdTables[seqid]; ok {
+		return t, nil
+	}
+	return 1, nil
+}
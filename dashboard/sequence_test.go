@@ -0,0 +1,70 @@
+package kubeless
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+const seqTestGFF3 = `##gff-version 3
+chrA	x	gene	3	14	.	+	.	ID=g1
+chrA	x	mRNA	3	14	.	+	.	ID=m1;Parent=g1
+chrA	x	exon	3	5	.	+	.	Parent=m1
+chrA	x	exon	9	14	.	+	.	Parent=m1
+chrA	x	CDS	3	5	.	+	0	ID=c1;Parent=m1
+chrA	x	CDS	9	14	.	+	0	ID=c1;Parent=m1
+chrA	x	gene	10	30	.	-	.	ID=g2
+chrB	x	gene	1	10	.	+	.	ID=g3
+##FASTA
+>chrA
+CCATGTTTAAATGGTAACC
+`
+
+func TestFeatureSequenceHandler(t *testing.T) {
+	st := newMemStorage()
+	err := storeGFFInforamtion(
+		strings.NewReader(seqTestGFF3), st, KEY_PREFIX+"-44689", newGFFReport(),
+	)
+	if err != nil {
+		t.Fatalf("error in storing gff3 %s", err)
+	}
+	r := chi.NewRouter()
+	r.Get("/genomes/{taxonid}/features/{id}/sequence", featureSequenceHandler(st))
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantSeq    string
+	}{
+		{"genomic", "/genomes/44689/features/g1/sequence", http.StatusOK, "ATGTTTAAATGG"},
+		{"spliced of transcript", "/genomes/44689/features/m1/sequence?type=spliced", http.StatusOK, "ATGAAATGG"},
+		{"protein of gene", "/genomes/44689/features/g1/sequence?type=protein", http.StatusOK, "MKW"},
+		{"missing feature", "/genomes/44689/features/g9/sequence", http.StatusNotFound, ""},
+		{"seqid without sequence", "/genomes/44689/features/g3/sequence", http.StatusNotFound, ""},
+		{"beyond the sequence", "/genomes/44689/features/g2/sequence", http.StatusBadRequest, ""},
+		{"invalid type", "/genomes/44689/features/g1/sequence?type=rna", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			res := &featSeqJsonAPI{}
+			if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+				t.Fatalf("error in decoding %s %s", w.Body.String(), err)
+			}
+			if got := res.Data.Attributes.Sequence; got != tt.wantSeq {
+				t.Errorf("sequence %s, want %s", got, tt.wantSeq)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package kubeless
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+)
+
+// memStorage is an in memory shared.Storage for the tests of the handlers
+type memStorage struct {
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+	sorted map[string][]shared.SortedMember
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{
+		hashes: make(map[string]map[string]string),
+		sorted: make(map[string][]shared.SortedMember),
+	}
+}
+
+func (m *memStorage) Get(key, field string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.hashes[key][field]
+	if !ok {
+		return "", shared.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m *memStorage) GetMany(key string, fields ...string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values := make([]string, 0, len(fields))
+	for _, f := range fields {
+		v, ok := m.hashes[key][f]
+		if !ok {
+			return nil, fmt.Errorf("field %s of %s does not exist", f, key)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (m *memStorage) Set(key, field, value string) error {
+	return m.SetMany(key, map[string]string{field: value})
+}
+
+func (m *memStorage) SetMany(key string, values map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hashes[key] == nil {
+		m.hashes[key] = make(map[string]string)
+	}
+	for f, v := range values {
+		m.hashes[key][f] = v
+	}
+	return nil
+}
+
+func (m *memStorage) Delete(key string, fields ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range fields {
+		delete(m.hashes[key], f)
+	}
+	return nil
+}
+
+func (m *memStorage) IsExist(key, field string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.hashes[key][field]
+	return ok
+}
+
+func (m *memStorage) ClearAll(prefix string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for k := range m.hashes {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.hashes, k)
+			n++
+		}
+	}
+	for k := range m.sorted {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.sorted, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memStorage) AddSorted(key string, members ...shared.SortedMember) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sorted[key] = append(m.sorted[key], members...)
+	sort.SliceStable(m.sorted[key], func(i, j int) bool {
+		return m.sorted[key][i].Score < m.sorted[key][j].Score
+	})
+	return nil
+}
+
+func (m *memStorage) RangeSorted(key string, min, max float64) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var members []string
+	for _, sm := range m.sorted[key] {
+		if sm.Score >= min && sm.Score <= max {
+			members = append(members, sm.Member)
+		}
+	}
+	return members, nil
+}
+
+func (m *memStorage) Close() error {
+	return nil
+}
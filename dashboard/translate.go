@@ -0,0 +1,123 @@
+package kubeless
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// the standard genetic code, the codons are in TCAG order of their
+// first, second and third bases
+const (
+	codonBases    = "TCAG"
+	standardCodes = "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"
+)
+
+// translationTables are the NCBI genetic codes as their differences from
+// the standard one
+var translationTables = map[int]map[string]byte{
+	1: {},
+	// vertebrate mitochondrial
+	2: {"AGA": '*', "AGG": '*', "ATA": 'M', "TGA": 'W'},
+	// yeast mitochondrial
+	3: {"ATA": 'M', "CTT": 'T', "CTC": 'T', "CTA": 'T', "CTG": 'T', "TGA": 'W'},
+	// mold, protozoan and coelenterate mitochondrial, used by the
+	// mitochondria of Dictyostelium
+	4: {"TGA": 'W'},
+	// invertebrate mitochondrial
+	5: {"AGA": 'S', "AGG": 'S', "ATA": 'M', "TGA": 'W'},
+	// ciliate, dasycladacean and hexamita nuclear
+	6: {"TAA": 'Q', "TAG": 'Q'},
+	// bacterial, archaeal and plant plastid
+	11: {},
+}
+
+// geneticCode returns the amino acids of all the codons of the NCBI
+// translation table
+func geneticCode(table int) (map[string]byte, error) {
+	diff, ok := translationTables[table]
+	if !ok {
+		return nil, fmt.Errorf("translation table %d is not supported", table)
+	}
+	code := make(map[string]byte, len(standardCodes))
+	for i := 0; i < len(standardCodes); i++ {
+		codon := string([]byte{
+			codonBases[i/16],
+			codonBases[i/4%4],
+			codonBases[i%4],
+		})
+		code[codon] = standardCodes[i]
+	}
+	for codon, aa := range diff {
+		code[codon] = aa
+	}
+	return code, nil
+}
+
+// translate returns the protein of the coding sequence, the codons with
+// ambiguous bases are X and the incomplete codon at the end along with the
+// final stop are left out
+func translate(cds string, table int) (string, error) {
+	code, err := geneticCode(table)
+	if err != nil {
+		return "", err
+	}
+	cds = strings.Replace(strings.ToUpper(cds), "U", "T", -1)
+	protein := make([]byte, 0, len(cds)/3)
+	for i := 0; i+3 <= len(cds); i += 3 {
+		aa, ok := code[cds[i:i+3]]
+		if !ok {
+			aa = 'X'
+		}
+		protein = append(protein, aa)
+	}
+	return strings.TrimSuffix(string(protein), "*"), nil
+}
+
+// seqIdTables are the translation tables of the reference sequences that
+// do not use the standard genetic code, read once from the environment
+var seqIdTables = loadSeqIdTables()
+
+// loadSeqIdTables reads the TRANSLATION_TABLES environment variable, the
+// invalid pairs are logged and left out
+func loadSeqIdTables() map[string]int {
+	tables, err := parseSeqIdTables(os.Getenv("TRANSLATION_TABLES"))
+	if err != nil {
+		log.Printf("error in reading TRANSLATION_TABLES %s", err)
+	}
+	return tables
+}
+
+// parseSeqIdTables parses the comma separated seqid=table pairs, for
+// example the mitochondrial DNA of Dictyostelium is DDB0169550=4. The
+// valid pairs are returned along with the error of any invalid one.
+func parseSeqIdTables(v string) (map[string]int, error) {
+	tables := make(map[string]int)
+	if len(strings.TrimSpace(v)) == 0 {
+		return tables, nil
+	}
+	var invalid []string
+	for _, pair := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			invalid = append(invalid, fmt.Sprintf("%s is not a seqid=table pair", pair))
+			continue
+		}
+		t, err := strconv.Atoi(kv[1])
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("table %s of %s is not a number", kv[1], kv[0]))
+			continue
+		}
+		if _, ok := translationTables[t]; !ok {
+			invalid = append(invalid, fmt.Sprintf("table %d of %s is not supported", t, kv[0]))
+			continue
+		}
+		tables[kv[0]] = t
+	}
+	if len(invalid) > 0 {
+		return tables, fmt.Errorf("%s", strings.Join(invalid, ", "))
+	}
+	return tables, nil
+}
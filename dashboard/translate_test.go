@@ -0,0 +1,105 @@
+package kubeless
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cds     string
+		table   int
+		want    string
+		wantErr bool
+	}{
+		{"standard", "ATGGCATGGTAA", 1, "MAW", false},
+		{"lower case rna", "augugguga", 1, "MW", false},
+		{"internal stop", "ATGTAAGGC", 1, "M*G", false},
+		{"incomplete codon", "ATGGCATG", 1, "MA", false},
+		{"ambiguous base", "ATGNNNGGC", 1, "MXG", false},
+		{"mitochondrial TGA", "ATGTGATGG", 4, "MWW", false},
+		{"vertebrate mitochondrial AGA", "ATGAGATAA", 2, "M*", false},
+		{"ciliate TAA", "ATGTAAGGC", 6, "MQG", false},
+		{"empty", "", 1, "", false},
+		{"unsupported table", "ATG", 99, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translate(tt.cds, tt.table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("translate() error %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("translate(%q, %d) = %q, want %q", tt.cds, tt.table, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneticCode(t *testing.T) {
+	code, err := geneticCode(1)
+	if err != nil {
+		t.Fatalf("geneticCode() error %s", err)
+	}
+	tests := map[string]byte{
+		"TTT": 'F', "TCA": 'S', "TAG": '*', "TGG": 'W',
+		"CTG": 'L', "ATG": 'M', "GGG": 'G', "AAA": 'K',
+	}
+	if len(code) != 64 {
+		t.Errorf("got %d codons, want 64", len(code))
+	}
+	for codon, want := range tests {
+		if got := code[codon]; got != want {
+			t.Errorf("codon %s is %c, want %c", codon, got, want)
+		}
+	}
+}
+
+func TestReverseComplement(t *testing.T) {
+	tests := []struct {
+		name string
+		seq  string
+		want string
+	}{
+		{"bases", "ATGC", "GCAT"},
+		{"lower case", "aacg", "cgtt"},
+		{"ambiguity codes", "RYKMBDN", "NHVKMRY"},
+		{"rna", "AUG", "CAT"},
+		{"unknown kept", "A-T", "A-T"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reverseComplement(tt.seq); got != tt.want {
+				t.Errorf("reverseComplement(%q) = %q, want %q", tt.seq, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeqIdTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"unset", "", map[string]int{}, false},
+		{"pairs", "DDB0169550=4, chrM=2", map[string]int{"DDB0169550": 4, "chrM": 2}, false},
+		{"not a pair", "DDB0169550", map[string]int{}, true},
+		{"not a number", "DDB0169550=four,chrM=2", map[string]int{"chrM": 2}, true},
+		{"unsupported table", "DDB0169550=99", map[string]int{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSeqIdTables(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSeqIdTables() error %v, want error %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSeqIdTables(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package kubeless
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maximum number of invalid lines listed in the report
+const maxLineErrors = 100
+
+// lineError is an invalid line of a gff3 file
+type lineError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// gffReport is the outcome of validating the lines of a gff3 file
+type gffReport struct {
+	Lines   int          `json:"lines"`
+	Skipped int          `json:"skipped"`
+	Errors  []*lineError `json:"errors"`
+	// Warnings are the valid lines with features outside of their
+	// declared sequence region
+	Warnings []*lineError `json:"warnings"`
+}
+
+func newGFFReport() *gffReport {
+	return &gffReport{
+		Errors:   make([]*lineError, 0),
+		Warnings: make([]*lineError, 0),
+	}
+}
+
+func (r *gffReport) add(line int, err error) {
+	r.Skipped++
+	if len(r.Errors) < maxLineErrors {
+		r.Errors = append(r.Errors, &lineError{Line: line, Message: err.Error()})
+	}
+}
+
+func (r *gffReport) warn(line int, err error) {
+	if len(r.Warnings) < maxLineErrors {
+		r.Warnings = append(r.Warnings, &lineError{Line: line, Message: err.Error()})
+	}
+}
+
+// GFF3LineValidator passes on the comments, the valid directives and
+// feature lines, the invalid ones are left out and added to the report.
+// Blank lines are ignored.
+func GFF3LineValidator(in <-chan string, rep *gffReport) (<-chan string, <-chan error, error) {
+	out := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		defer close(errc)
+		regions := make(map[string]*sequenceRegion)
+		ids := make(map[string]*idUse)
+		for line := range in {
+			rep.Lines++
+			line = strings.TrimSuffix(line, "\n")
+			if len(strings.TrimSpace(line)) == 0 {
+				continue
+			}
+			var err error
+			switch {
+			case strings.HasPrefix(line, "##"):
+				err = validateDirective(line)
+			case strings.HasPrefix(line, "#"):
+			default:
+				err = validateLine(line)
+				if err == nil {
+					err = checkId(line, rep.Lines, ids)
+				}
+			}
+			if err != nil {
+				rep.add(rep.Lines, err)
+				continue
+			}
+			if strings.HasPrefix(line, "##sequence-region") {
+				sr, _ := parseSequenceRegion(line)
+				regions[sr.SeqId] = sr
+			} else if err := checkRegion(line, regions); err != nil {
+				rep.warn(rep.Lines, err)
+			}
+			out <- fmt.Sprintf("%s\n", line)
+		}
+	}()
+	return out, errc, nil
+}
+
+// validateGFF3 validates all the lines of the gff3 file
+func validateGFF3(r io.Reader) (*gffReport, error) {
+	rep := newGFFReport()
+	linec, errc, err := GFF3LineProducer(r)
+	if err != nil {
+		return rep, fmt.Errorf("unable to create gff3 producer %s", err)
+	}
+	featc, fastac, serrc, err := GFF3SectionSplitter(linec)
+	if err != nil {
+		return rep, fmt.Errorf("unable to create gff3 section splitter %s", err)
+	}
+	validc, verrc, err := GFF3LineValidator(featc, rep)
+	if err != nil {
+		return rep, fmt.Errorf("unable to create gff3 validator %s", err)
+	}
+	go func() {
+		for range fastac {
+		}
+	}()
+	for range validc {
+	}
+	return rep, WaitForPipeline(errc, serrc, verrc)
+}
+
+// validateDirective checks the directives with a defined format
+func validateDirective(line string) error {
+	s := strings.Fields(line)
+	switch s[0] {
+	case "##gff-version":
+		if len(s) < 2 || !strings.HasPrefix(s[1], "3") {
+			return fmt.Errorf("only gff version 3 is supported")
+		}
+	case "##sequence-region":
+		if _, err := parseSequenceRegion(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idUse is the first feature line with an ID
+type idUse struct {
+	line  int
+	ftype string
+	seqid string
+}
+
+// checkId checks that an ID is only used again by the lines of the same
+// multi-line feature, which have the same type and seqid
+func checkId(line string, n int, ids map[string]*idUse) error {
+	s := strings.Split(line, "\t")
+	attrs, _ := parseAttributes(s[8])
+	id := attrs.first("ID")
+	if len(id) == 0 {
+		return nil
+	}
+	u, ok := ids[id]
+	if !ok {
+		ids[id] = &idUse{line: n, ftype: s[2], seqid: s[0]}
+		return nil
+	}
+	if u.ftype != s[2] || u.seqid != s[0] {
+		return fmt.Errorf(
+			"ID %s is already used by the %s on %s at line %d",
+			id, u.ftype, u.seqid, u.line,
+		)
+	}
+	return nil
+}
+
+// checkRegion checks if the feature of a valid line is within the
+// declared sequence region of its seqid
+func checkRegion(line string, regions map[string]*sequenceRegion) error {
+	if strings.HasPrefix(line, "#") {
+		return nil
+	}
+	s := strings.Split(line, "\t")
+	sr, ok := regions[s[0]]
+	if !ok {
+		return nil
+	}
+	start, _ := strconv.Atoi(s[3])
+	end, _ := strconv.Atoi(s[4])
+	if start < sr.Start || end > sr.End {
+		return fmt.Errorf(
+			"%s %d..%d is outside of the sequence region %s %d..%d",
+			s[2], start, end, sr.SeqId, sr.Start, sr.End,
+		)
+	}
+	return nil
+}
+
+// validateLine checks the columns of a feature line
+func validateLine(line string) error {
+	s := strings.Split(line, "\t")
+	if len(s) != 9 {
+		return fmt.Errorf("expected 9 tab separated columns, got %d", len(s))
+	}
+	if len(s[0]) == 0 || s[0] == "." {
+		return fmt.Errorf("missing seqid")
+	}
+	if len(s[2]) == 0 || s[2] == "." {
+		return fmt.Errorf("missing type")
+	}
+	start, err := strconv.Atoi(s[3])
+	if err != nil || start < 1 {
+		return fmt.Errorf("start %s is not a positive integer", s[3])
+	}
+	end, err := strconv.Atoi(s[4])
+	if err != nil || end < 1 {
+		return fmt.Errorf("end %s is not a positive integer", s[4])
+	}
+	if start > end {
+		return fmt.Errorf("start %d is greater than end %d", start, end)
+	}
+	if s[5] != "." {
+		if _, err := strconv.ParseFloat(s[5], 64); err != nil {
+			return fmt.Errorf("score %s is not a number", s[5])
+		}
+	}
+	switch s[6] {
+	case "+", "-", ".", "?":
+	default:
+		return fmt.Errorf("strand %s is not one of +, -, . or ?", s[6])
+	}
+	switch s[7] {
+	case "0", "1", "2":
+	case ".":
+		if s[2] == "CDS" {
+			return fmt.Errorf("missing phase of CDS")
+		}
+	default:
+		return fmt.Errorf("phase %s is not one of 0, 1, 2 or .", s[7])
+	}
+	if _, err := parseAttributes(s[8]); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package kubeless
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateLine(t *testing.T) {
+	cols := func(c ...string) string { return strings.Join(c, "\t") }
+	tests := []struct {
+		name string
+		line string
+		// part of the expected error message, empty for a valid line
+		wantErr string
+	}{
+		{
+			"valid gene",
+			cols("DDB0232428", "dictyBase", "gene", "1890", "3287", ".", "+", ".", "ID=DDB_G0267178"),
+			"",
+		},
+		{
+			"valid CDS",
+			cols("DDB0232428", "dictyBase", "CDS", "1890", "3287", "0.5", "-", "2", "Parent=DDB0216437"),
+			"",
+		},
+		{
+			"unknown strand",
+			cols("DDB0232428", "dictyBase", "gene", "1", "10", ".", "?", ".", "ID=g1"),
+			"",
+		},
+		{
+			"too few columns",
+			cols("DDB0232428", "dictyBase", "gene", "1", "10"),
+			"expected 9 tab separated columns, got 5",
+		},
+		{
+			"missing seqid",
+			cols(".", "dictyBase", "gene", "1", "10", ".", "+", ".", "ID=g1"),
+			"missing seqid",
+		},
+		{
+			"missing type",
+			cols("DDB0232428", "dictyBase", ".", "1", "10", ".", "+", ".", "ID=g1"),
+			"missing type",
+		},
+		{
+			"zero start",
+			cols("DDB0232428", "dictyBase", "gene", "0", "10", ".", "+", ".", "ID=g1"),
+			"start 0 is not a positive integer",
+		},
+		{
+			"invalid end",
+			cols("DDB0232428", "dictyBase", "gene", "1", "ten", ".", "+", ".", "ID=g1"),
+			"end ten is not a positive integer",
+		},
+		{
+			"start after end",
+			cols("DDB0232428", "dictyBase", "gene", "20", "10", ".", "+", ".", "ID=g1"),
+			"start 20 is greater than end 10",
+		},
+		{
+			"invalid score",
+			cols("DDB0232428", "dictyBase", "gene", "1", "10", "high", "+", ".", "ID=g1"),
+			"score high is not a number",
+		},
+		{
+			"invalid strand",
+			cols("DDB0232428", "dictyBase", "gene", "1", "10", ".", "x", ".", "ID=g1"),
+			"strand x is not one of",
+		},
+		{
+			"CDS without phase",
+			cols("DDB0232428", "dictyBase", "CDS", "1", "10", ".", "+", ".", "Parent=m1"),
+			"missing phase of CDS",
+		},
+		{
+			"invalid phase",
+			cols("DDB0232428", "dictyBase", "CDS", "1", "10", ".", "+", "3", "Parent=m1"),
+			"phase 3 is not one of",
+		},
+		{
+			"invalid attributes",
+			cols("DDB0232428", "dictyBase", "gene", "1", "10", ".", "+", ".", "ID"),
+			"attribute ID is not a tag=value pair",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLine(tt.line)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("validateLine() error %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateLine() is expected to fail with %s", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateLine() error %s, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckId(t *testing.T) {
+	cols := func(c ...string) string { return strings.Join(c, "\t") }
+	ids := make(map[string]*idUse)
+	tests := []struct {
+		name string
+		line string
+		// part of the expected error message, empty for a valid line
+		wantErr string
+	}{
+		{
+			"first use",
+			cols("chrA", "dictyBase", "CDS", "1", "10", ".", "+", "0", "ID=c1;Parent=m1"),
+			"",
+		},
+		{
+			"line of the same feature",
+			cols("chrA", "dictyBase", "CDS", "20", "30", ".", "+", "0", "ID=c1;Parent=m1"),
+			"",
+		},
+		{
+			"without ID",
+			cols("chrA", "dictyBase", "exon", "1", "10", ".", "+", ".", "Parent=m1"),
+			"",
+		},
+		{
+			"other type",
+			cols("chrA", "dictyBase", "gene", "1", "30", ".", "+", ".", "ID=c1"),
+			"ID c1 is already used by the CDS on chrA at line 1",
+		},
+		{
+			"other seqid",
+			cols("chrB", "dictyBase", "CDS", "1", "10", ".", "+", "0", "ID=c1;Parent=m1"),
+			"ID c1 is already used by the CDS on chrA at line 1",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkId(tt.line, i+1, ids)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("checkId() error %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("checkId() is expected to fail with %s", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkId() error %s, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package kubeless
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"github.com/spacemonkeygo/errors"
+	"github.com/spacemonkeygo/errors/errhttp"
+)
+
+// ADMIN_TOKEN_ENV is the environment variable with the bearer token for
+// the cache admin routes, the routes are disabled when it is not set.
+const ADMIN_TOKEN_ENV = "CACHE_ADMIN_TOKEN"
+
+var (
+	cacheAdminRegxp = regexp.MustCompile(`^/(?:(\d+)/cache|cache/(refresh|stats))$`)
+	// ErrForbidden is for admin routes that are disabled
+	ErrForbidden = apherror.Errhttp.NewClass(
+		"Forbidden",
+		errhttp.SetStatusCode(http.StatusForbidden),
+		errors.SetData(shared.TitleErrKey, "Forbidden"),
+	)
+	stats = &cacheStats{}
+)
+
+// cacheStats counts the cache activity of this replica since it started
+type cacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Stores    int64 `json:"stores"`
+	Errors    int64 `json:"errors"`
+	Shared    int64 `json:"shared_fetches"`
+	Stale     int64 `json:"stale_hits"`
+	Refreshed int64 `json:"refreshed"`
+}
+
+func (c *cacheStats) snapshot() *cacheStats {
+	return &cacheStats{
+		Hits:      atomic.LoadInt64(&c.Hits),
+		Misses:    atomic.LoadInt64(&c.Misses),
+		Stores:    atomic.LoadInt64(&c.Stores),
+		Errors:    atomic.LoadInt64(&c.Errors),
+		Shared:    atomic.LoadInt64(&c.Shared),
+		Stale:     atomic.LoadInt64(&c.Stale),
+		Refreshed: atomic.LoadInt64(&c.Refreshed),
+	}
+}
+
+type CacheAdminJsonAPI struct {
+	Meta interface{} `json:"meta"`
+}
+
+type CacheStatsMeta struct {
+	Backend string      `json:"backend"`
+	Stats   *cacheStats `json:"stats"`
+}
+
+type CacheRefreshMeta struct {
+	Refreshed []string          `json:"refreshed"`
+	Failed    map[string]string `json:"failed"`
+}
+
+type CacheDeleteMeta struct {
+	ID      string   `json:"id"`
+	Deleted []string `json:"deleted"`
+	// number of keys removed for citations, references and full text
+	Cleared int64 `json:"cleared"`
+}
+
+// CacheRefreshRequest is the body of POST /cache/refresh
+type CacheRefreshRequest struct {
+	Ids []string `json:"ids"`
+}
+
+func cacheAdminHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, body string, m []string) (string, error) {
+	if err := authorizeAdmin(r); err != nil {
+		return errorResponse(w, err)
+	}
+	if cache == nil {
+		return errorResponse(
+			w,
+			apherror.ErrNotFound.New("no cache is configured"),
+		)
+	}
+	switch {
+	case len(m[1]) > 0:
+		if r.Method != "DELETE" {
+			return methodNotAllowed(w, r)
+		}
+		return deleteCacheHandler(ctx, w, m[1])
+	case m[2] == "refresh":
+		if r.Method != "POST" {
+			return methodNotAllowed(w, r)
+		}
+		return refreshCacheHandler(ctx, w, r, body)
+	default:
+		if r.Method != "GET" {
+			return methodNotAllowed(w, r)
+		}
+		return writeAdminMeta(w, &CacheStatsMeta{
+			Backend: fmt.Sprintf("%T", cache),
+			Stats:   stats.snapshot(),
+		})
+	}
+}
+
+// authorizeAdmin matches the bearer token of the request with the one in
+// the environment
+func authorizeAdmin(r *http.Request) error {
+	token := os.Getenv(ADMIN_TOKEN_ENV)
+	if len(token) == 0 {
+		return ErrForbidden.New("cache admin routes are not enabled")
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return apherror.ErrAuthentication.New("bearer token is missing")
+	}
+	given := strings.TrimPrefix(auth, "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		return apherror.ErrAuthentication.New("bearer token does not match")
+	}
+	return nil
+}
+
+func deleteCacheHandler(ctx context.Context, w http.ResponseWriter, id string) (string, error) {
+	rkey := fmt.Sprintf("%s/%s", REDIS_KEY, id)
+	var deleted []string
+	for _, k := range []string{rkey, rkey + staleSuffix} {
+		if err := cache.Delete(ctx, k); err != nil {
+			return errorResponse(
+				w,
+				apherror.Errhttp.New("error in deleting key %s %s", k, err),
+			)
+		}
+		deleted = append(deleted, k)
+	}
+	// citations, references and full text
+	n, err := cache.ClearAll(ctx, rkey+"/")
+	if err != nil {
+		return errorResponse(
+			w,
+			apherror.Errhttp.New("error in clearing keys of %s %s", rkey, err),
+		)
+	}
+	log.Printf("deleted cache of publication %s", id)
+	return writeAdminMeta(w, &CacheDeleteMeta{
+		ID:      id,
+		Deleted: deleted,
+		Cleared: n,
+	})
+}
+
+func refreshCacheHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, body string) (string, error) {
+	req := &CacheRefreshRequest{}
+	if err := json.Unmarshal([]byte(body), req); err != nil {
+		cls := apherror.Errhttp.NewClass(
+			http.StatusText(http.StatusBadRequest),
+			errhttp.SetStatusCode(http.StatusBadRequest),
+			errors.SetData(shared.TitleErrKey, "invalid request body"),
+			errors.SetData(shared.PointerErrKey, "/ids"),
+		)
+		return errorResponse(w, cls.New("error in decoding body %s", err))
+	}
+	meta := &CacheRefreshMeta{
+		Refreshed: make([]string, 0),
+		Failed:    make(map[string]string),
+	}
+	for _, id := range req.Ids {
+		if !pubRegxp.MatchString("/" + id) {
+			meta.Failed[id] = "not a valid pubmed id"
+			continue
+		}
+		rkey := fmt.Sprintf("%s/%s", REDIS_KEY, id)
+		self := publicationLink(r, id)
+		_, err := fetchAndStore(ctx, rkey, pubCacheTTL, func(ctx context.Context) ([]byte, error) {
+			return fetchPublication(ctx, id, self)
+		})
+		if err != nil {
+			meta.Failed[id] = errhttp.GetErrorBody(err)
+			continue
+		}
+		atomic.AddInt64(&stats.Refreshed, 1)
+		meta.Refreshed = append(meta.Refreshed, id)
+	}
+	return writeAdminMeta(w, meta)
+}
+
+func writeAdminMeta(w http.ResponseWriter, meta interface{}) (string, error) {
+	b, err := json.Marshal(&CacheAdminJsonAPI{Meta: meta})
+	if err != nil {
+		return errorResponse(
+			w,
+			apherror.ErrStructMarshal.New(
+				"error in making final response %s",
+				err.Error(),
+			),
+		)
+	}
+	return string(b), nil
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) (string, error) {
+	return errorResponse(
+		w,
+		apherror.ErrMethodNotAllowed.New("%s not allowed", r.Method),
+	)
+}
+
+// publicationLink makes the link of a publication from any request to
+// this function
+func publicationLink(r *http.Request, id string) string {
+	path := r.Header.Get("X-Original-Uri")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return fmt.Sprintf(
+		"%s://%s%s/%s",
+		r.Header.Get("X-Forwarded-Proto"),
+		r.Host,
+		strings.TrimSuffix(path, r.URL.Path),
+		id,
+	)
+}
@@ -0,0 +1,251 @@
+package kubeless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"github.com/spacemonkeygo/errors/errhttp"
+)
+
+const (
+	defaultPageSize = 25
+	maxPageSize     = 1000
+)
+
+type LinkedPubJsonAPI struct {
+	Data  []*LinkedPubData `json:"data"`
+	Links *PaginationLinks `json:"links"`
+	Meta  *PaginationMeta  `json:"meta"`
+}
+
+type LinkedPubData struct {
+	Type       string             `json:"type"`
+	ID         string             `json:"id"`
+	Attributes *LinkedPublication `json:"attributes"`
+}
+
+type PaginationLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+}
+
+type PaginationMeta struct {
+	Pagination *Pagination `json:"pagination"`
+}
+
+type Pagination struct {
+	Records int64 `json:"records"`
+	Total   int64 `json:"total"`
+	Size    int64 `json:"size"`
+	Number  int64 `json:"number"`
+}
+
+// LinkedPublication is the summary of a publication that either cites or
+// is referenced by another publication
+type LinkedPublication struct {
+	Title        string `json:"title"`
+	Authors      string `json:"authors"`
+	Journal      string `json:"journal"`
+	PubYear      int64  `json:"pub_year,omitempty"`
+	Volume       string `json:"volume,omitempty"`
+	Issue        string `json:"issue,omitempty"`
+	Page         string `json:"page,omitempty"`
+	Source       string `json:"source"`
+	PubmedURL    string `json:"pubmed_url,omitempty"`
+	CitedByCount int64  `json:"cited_by_count,omitempty"`
+}
+
+type EuroPMCLink struct {
+	ID                  string `json:"id"`
+	Source              string `json:"source"`
+	Title               string `json:"title"`
+	AuthorString        string `json:"authorString"`
+	JournalAbbreviation string `json:"journalAbbreviation"`
+	PubYear             int64  `json:"pubYear"`
+	Volume              string `json:"volume"`
+	Issue               string `json:"issue"`
+	PageInfo            string `json:"pageInfo"`
+	CitedByCount        int64  `json:"citedByCount"`
+}
+
+type EuroPMCLinkList struct {
+	HitCount     int64 `json:"hitCount"`
+	CitationList struct {
+		Citation []*EuroPMCLink `json:"citation"`
+	} `json:"citationList"`
+	ReferenceList struct {
+		Reference []*EuroPMCLink `json:"reference"`
+	} `json:"referenceList"`
+}
+
+// Links returns the citations or references present in the list
+func (l *EuroPMCLinkList) Links() []*EuroPMCLink {
+	if len(l.CitationList.Citation) > 0 {
+		return l.CitationList.Citation
+	}
+	return l.ReferenceList.Reference
+}
+
+func linkedPublicationHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id, rel string) (string, error) {
+	if len(negotiateMediaType(r.Header.Get("Accept"), []string{jsonAPIMediaType})) == 0 {
+		json, status, err := shared.JSONAPIError(
+			apherror.ErrNotAcceptable.New(
+				"%s is not supported, use %s",
+				r.Header.Get("Accept"),
+				jsonAPIMediaType,
+			),
+		)
+		w.WriteHeader(status)
+		return json, err
+	}
+	number, err := pageParam(r, "page[number]", 1, 0)
+	if err != nil {
+		return queryParamError(w, "page[number]", err)
+	}
+	size, err := pageParam(r, "page[size]", defaultPageSize, maxPageSize)
+	if err != nil {
+		return queryParamError(w, "page[size]", err)
+	}
+	rkey := fmt.Sprintf(
+		"%s/%s/%s?page=%d&size=%d",
+		REDIS_KEY, id, rel, number, size,
+	)
+	b, err := cachedFetch(ctx, rkey, 24*time.Hour, func(ctx context.Context) ([]byte, error) {
+		list := &EuroPMCLinkList{}
+		err := fetchEuroPMC(
+			ctx,
+			fmt.Sprintf(
+				"%s/MED/%s/%s?format=json&page=%d&pageSize=%d",
+				EuroPMCBaseURL, id, rel, number, size,
+			),
+			list,
+		)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(EuroPMC2LinkedPubs(list, r, number, size))
+		if err != nil {
+			return nil, apherror.ErrStructMarshal.New(
+				"error in making final response %s",
+				err.Error(),
+			)
+		}
+		return b, nil
+	})
+	if err != nil {
+		return errorResponse(w, err)
+	}
+	return string(b), nil
+}
+
+// EuroPMC2LinkedPubs converts a page of Europe PMC citations or references
+// into a JSON:API collection
+func EuroPMC2LinkedPubs(list *EuroPMCLinkList, r *http.Request, number, size int64) *LinkedPubJsonAPI {
+	data := make([]*LinkedPubData, 0)
+	for _, l := range list.Links() {
+		lp := &LinkedPublication{
+			Title:        l.Title,
+			Authors:      l.AuthorString,
+			Journal:      l.JournalAbbreviation,
+			PubYear:      l.PubYear,
+			Volume:       l.Volume,
+			Issue:        l.Issue,
+			Page:         l.PageInfo,
+			Source:       l.Source,
+			CitedByCount: l.CitedByCount,
+		}
+		if l.Source == "MED" && len(l.ID) > 0 {
+			lp.PubmedURL = fmt.Sprintf("https://pubmed.gov/%s", l.ID)
+		}
+		data = append(data, &LinkedPubData{
+			Type:       "publications",
+			ID:         l.ID,
+			Attributes: lp,
+		})
+	}
+	last := (list.HitCount + size - 1) / size
+	if last < 1 {
+		last = 1
+	}
+	links := &PaginationLinks{
+		Self:  generatePageLink(r, number, size),
+		First: generatePageLink(r, 1, size),
+		Last:  generatePageLink(r, last, size),
+	}
+	if number > 1 {
+		links.Prev = generatePageLink(r, number-1, size)
+	}
+	if number < last {
+		links.Next = generatePageLink(r, number+1, size)
+	}
+	return &LinkedPubJsonAPI{
+		Data:  data,
+		Links: links,
+		Meta: &PaginationMeta{
+			Pagination: &Pagination{
+				Records: list.HitCount,
+				Total:   last,
+				Size:    size,
+				Number:  number,
+			},
+		},
+	}
+}
+
+// pageParam reads a positive integer pagination parameter, max of zero
+// means there is no upper limit
+func pageParam(r *http.Request, name string, def, max int64) (int64, error) {
+	v := r.URL.Query().Get(name)
+	if len(v) == 0 {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s has to be a positive integer", name)
+	}
+	if max > 0 && n > max {
+		return 0, fmt.Errorf("%s cannot be more than %d", name, max)
+	}
+	return n, nil
+}
+
+func queryParamError(w http.ResponseWriter, param string, err error) (string, error) {
+	txt := http.StatusText(http.StatusBadRequest)
+	cls := apherror.Errhttp.NewClass(
+		txt,
+		errhttp.SetStatusCode(http.StatusBadRequest),
+	)
+	cls.MustAddData(shared.TitleErrKey, "invalid query parameter")
+	cls.MustAddData(shared.ParamErrKey, param)
+	json, _, errn := shared.JSONAPIError(cls.New(err.Error()))
+	w.WriteHeader(http.StatusBadRequest)
+	return json, errn
+}
+
+func generatePageLink(r *http.Request, number, size int64) string {
+	path := r.Header.Get("X-Original-Uri")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	params := url.Values{}
+	params.Set("page[number]", strconv.FormatInt(number, 10))
+	params.Set("page[size]", strconv.FormatInt(size, 10))
+	return fmt.Sprintf(
+		"%s://%s%s?%s",
+		r.Header.Get("X-Forwarded-Proto"),
+		r.Host,
+		path,
+		params.Encode(),
+	)
+}
@@ -0,0 +1,242 @@
+package kubeless
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	lockSuffix  = ":lock"
+	staleSuffix = ":stale"
+	// the lock is extended while the fetch runs, so it only expires this
+	// long after the replica holding it dies
+	lockTTL = 10 * time.Second
+	// duration for waiting on another replica to populate the cache
+	// before serving a stale value
+	lockWait     = 3 * time.Second
+	lockPollTime = 100 * time.Millisecond
+	// duration for which an expired value is kept around to be served
+	// while another replica is refreshing it
+	staleTTL = 24 * time.Hour
+)
+
+// deadline of a fetch shared by concurrent requests, it is not tied to any
+// one of them
+var sharedFetchTimeout = functionTimeout(os.Getenv("FUNC_TIMEOUT")) * 9 / 10
+
+var fetchGroup singleflight.Group
+
+// FetchFunc retrieves the value that is stored in the cache
+type FetchFunc func(context.Context) ([]byte, error)
+
+// cachedFetch returns the value of the key from the cache, on a miss the
+// value is retrieved with fetch and then stored in the cache. Concurrent
+// misses of the same key in this process share a single fetch, and if the
+// cache is a Locker only the replica holding the lock does the fetch while
+// the others wait for it, serve a stale value or take over the lock if that
+// replica dies. The shared fetch runs on
+// its own context, so a caller that gives up does not cancel it for the
+// others.
+func cachedFetch(ctx context.Context, rkey string, ttl time.Duration, fetch FetchFunc) ([]byte, error) {
+	if cache == nil {
+		log.Println("no cache")
+		return fetch(ctx)
+	}
+	if v, ok := cacheLookup(ctx, rkey); ok {
+		return v, nil
+	}
+	atomic.AddInt64(&stats.Misses, 1)
+	ch := fetchGroup.DoChan(rkey, func() (interface{}, error) {
+		fctx, cancel := context.WithTimeout(context.Background(), sharedFetchTimeout)
+		defer cancel()
+		return populateCache(fctx, rkey, ttl, fetch)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ErrUpstreamTimeout.New(
+			"timed out in waiting for key %s %s",
+			rkey, ctx.Err(),
+		)
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		if res.Shared {
+			atomic.AddInt64(&stats.Shared, 1)
+			log.Printf("shared the fetch of key %s", rkey)
+		}
+		return res.Val.([]byte), nil
+	}
+}
+
+func cacheLookup(ctx context.Context, rkey string) ([]byte, bool) {
+	v, ok, err := cache.Get(ctx, rkey)
+	if err != nil {
+		atomic.AddInt64(&stats.Errors, 1)
+		log.Printf("error in getting key %s %s", rkey, err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	atomic.AddInt64(&stats.Hits, 1)
+	log.Printf("got key %s from cache", rkey)
+	return v, true
+}
+
+func populateCache(ctx context.Context, rkey string, ttl time.Duration, fetch FetchFunc) ([]byte, error) {
+	locker, ok := cache.(shared.Locker)
+	if !ok {
+		return fetchAndStore(ctx, rkey, ttl, fetch)
+	}
+	token, err := shared.NewToken()
+	if err != nil {
+		log.Printf("error in generating lock token %s", err)
+		return fetchAndStore(ctx, rkey, ttl, fetch)
+	}
+	lkey := rkey + lockSuffix
+	acquired, err := locker.Lock(ctx, lkey, token, lockTTL)
+	if err != nil {
+		log.Printf("error in acquiring lock %s %s", lkey, err)
+		return fetchAndStore(ctx, rkey, ttl, fetch)
+	}
+	if acquired {
+		return fetchWithLock(ctx, locker, lkey, token, rkey, ttl, fetch)
+	}
+	log.Printf("lock %s is held by another replica", lkey)
+	v, acquired, err := waitForCache(ctx, locker, lkey, token, rkey)
+	if err != nil {
+		return nil, err
+	}
+	if acquired {
+		return fetchWithLock(ctx, locker, lkey, token, rkey, ttl, fetch)
+	}
+	return v, nil
+}
+
+// fetchWithLock fetches the value while holding the lock, which is
+// extended until the fetch is over so that a slow upstream does not let
+// another replica take it
+func fetchWithLock(ctx context.Context, locker shared.Locker, lkey, token, rkey string, ttl time.Duration, fetch FetchFunc) ([]byte, error) {
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		if err := locker.Unlock(ctx, lkey, token); err != nil {
+			log.Printf("error in releasing lock %s %s", lkey, err)
+		}
+	}()
+	go keepLock(ctx, locker, lkey, token, done)
+	// the replica that held the lock before might have populated the
+	// cache
+	if v, ok := cacheLookup(ctx, rkey); ok {
+		return v, nil
+	}
+	return fetchAndStore(ctx, rkey, ttl, fetch)
+}
+
+// keepLock extends the lock until done is closed or the lock is lost
+func keepLock(ctx context.Context, locker shared.Locker, lkey, token string, done <-chan struct{}) {
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := locker.Extend(ctx, lkey, token, lockTTL)
+			if err != nil {
+				log.Printf("error in extending lock %s %s", lkey, err)
+				continue
+			}
+			if !ok {
+				log.Printf("lock %s is lost", lkey)
+				return
+			}
+		}
+	}
+}
+
+// waitForCache polls the cache until the key is populated by the replica
+// holding the lock, a stale value is returned if it takes longer than
+// lockWait. The lock is acquired, and true returned, if that replica
+// releases it without populating the cache or dies.
+func waitForCache(ctx context.Context, locker shared.Locker, lkey, token, rkey string) ([]byte, bool, error) {
+	ticker := time.NewTicker(lockPollTime)
+	defer ticker.Stop()
+	timer := time.NewTimer(lockWait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false, ErrUpstreamTimeout.New(
+				"timed out in waiting for key %s %s",
+				rkey, ctx.Err(),
+			)
+		case <-timer.C:
+			if v, ok, err := getStale(ctx, rkey); err == nil && ok {
+				atomic.AddInt64(&stats.Stale, 1)
+				log.Printf("got stale key %s from cache", rkey)
+				return v, false, nil
+			}
+		case <-ticker.C:
+			if v, ok := cacheLookup(ctx, rkey); ok {
+				return v, false, nil
+			}
+			acquired, err := locker.Lock(ctx, lkey, token, lockTTL)
+			if err != nil {
+				log.Printf("error in acquiring lock %s %s", lkey, err)
+				continue
+			}
+			if acquired {
+				return nil, true, nil
+			}
+		}
+	}
+}
+
+func fetchAndStore(ctx context.Context, rkey string, ttl time.Duration, fetch FetchFunc) ([]byte, error) {
+	v, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Set(ctx, rkey, v, ttl); err != nil {
+		atomic.AddInt64(&stats.Errors, 1)
+		log.Printf("error in setting key %s %s", rkey, err)
+		return v, nil
+	}
+	atomic.AddInt64(&stats.Stores, 1)
+	log.Printf("stored key %s in cache", rkey)
+	if err := setStale(ctx, rkey, v, ttl+staleTTL); err != nil {
+		log.Printf("error in setting stale key %s %s", rkey, err)
+	}
+	return v, nil
+}
+
+// getStale returns the stale copy of the key, it is only looked up in the
+// cache shared by the replicas
+func getStale(ctx context.Context, rkey string) ([]byte, bool, error) {
+	if s, ok := cache.(shared.Sharer); ok {
+		return s.GetShared(ctx, rkey+staleSuffix)
+	}
+	return cache.Get(ctx, rkey+staleSuffix)
+}
+
+// setStale keeps a copy of the value for the replicas that wait on the
+// lock, so it is only needed for a Locker and is never kept locally
+func setStale(ctx context.Context, rkey string, v []byte, d time.Duration) error {
+	if _, ok := cache.(shared.Locker); !ok {
+		return nil
+	}
+	if s, ok := cache.(shared.Sharer); ok {
+		return s.SetShared(ctx, rkey+staleSuffix, v, d)
+	}
+	return cache.Set(ctx, rkey+staleSuffix, v, d)
+}
@@ -0,0 +1,224 @@
+package kubeless
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	jsonAPIMediaType = "application/vnd.api+json"
+	jsonMediaType    = "application/json"
+	bibtexMediaType  = "application/x-bibtex"
+	risMediaType     = "application/x-research-info-systems"
+)
+
+// supportedMediaTypes are the representations of a publication in order of
+// preference, the first one is used when the client accepts anything.
+var supportedMediaTypes = []string{
+	jsonAPIMediaType,
+	jsonMediaType,
+	bibtexMediaType,
+	risMediaType,
+}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// negotiateMediaType picks the best media type out of supported for the
+// given Accept header value. The quality of a media type is the one of the
+// most specific range that matches it (RFC 7231 section 5.3.2), the ones
+// with zero quality are not acceptable and among the rest the order of
+// supported breaks ties. It returns an empty string if none of the
+// supported media types are acceptable.
+func negotiateMediaType(accept string, supported []string) string {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return supported[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestQuality := "", 0.0
+	for _, mt := range supported {
+		quality, specificity := 0.0, -1
+		for _, ar := range ranges {
+			s := matchMediaType(ar.mediaType, mt)
+			if s > specificity {
+				quality, specificity = ar.quality, s
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = mt, quality
+		}
+	}
+	return best
+}
+
+// parseAccept splits an Accept header into media ranges with their quality
+// value
+func parseAccept(accept string) []*acceptRange {
+	var ranges []*acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mt := strings.ToLower(strings.TrimSpace(params[0]))
+		if len(mt) == 0 {
+			continue
+		}
+		ar := &acceptRange{mediaType: mt, quality: 1}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) != 2 || strings.ToLower(kv[0]) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				continue
+			}
+			ar.quality = q
+		}
+		ranges = append(ranges, ar)
+	}
+	return ranges
+}
+
+// matchMediaType returns how specific the range matching the media type
+// is, 2 for the same type, 1 for type/* and 0 for */*. It is -1 if the
+// range does not match.
+func matchMediaType(pattern, mt string) int {
+	switch {
+	case pattern == mt:
+		return 2
+	case pattern == "*/*":
+		return 0
+	case strings.HasSuffix(pattern, "/*") &&
+		strings.HasPrefix(mt, strings.TrimSuffix(pattern, "*")):
+		return 1
+	}
+	return -1
+}
+
+// renderPublication converts the cached JSON:API representation of a
+// publication into the given media type.
+func renderPublication(b []byte, mt string) (string, error) {
+	if mt == jsonAPIMediaType {
+		return string(b), nil
+	}
+	pub := &PubJsonAPI{}
+	if err := json.Unmarshal(b, pub); err != nil {
+		return "", fmt.Errorf("error in decoding publication %s", err)
+	}
+	if pub.Data == nil || pub.Data.Attributes == nil {
+		return "", fmt.Errorf("publication has no attributes")
+	}
+	switch mt {
+	case jsonMediaType:
+		ct, err := json.Marshal(pub.Data.Attributes)
+		if err != nil {
+			return "", fmt.Errorf("error in encoding publication %s", err)
+		}
+		return string(ct), nil
+	case bibtexMediaType:
+		return Pub2Bibtex(pub.Data.ID, pub.Data.Attributes), nil
+	case risMediaType:
+		return Pub2RIS(pub.Data.Attributes), nil
+	}
+	return "", fmt.Errorf("unsupported media type %s", mt)
+}
+
+// bibtexEscaper replaces the braces, which BibTeX counts even after a
+// backslash, with commands so that a braced value always ends where it is
+// expected. Backslashes and percent signs are escaped for LaTeX.
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash `,
+	"{", `\textbraceleft `,
+	"}", `\textbraceright `,
+	"%", `\%`,
+)
+
+// Pub2Bibtex formats a publication as a BibTeX article entry
+func Pub2Bibtex(id string, pub *Publication) string {
+	var authors []string
+	for _, a := range pub.Authors {
+		authors = append(authors, bibtexAuthor(a))
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "@article{pmid%s,\n", id)
+	fields := [][2]string{
+		{"author", strings.Join(authors, " and ")},
+		{"title", pub.Title},
+		{"journal", pub.Journal},
+		{"year", pubYear(pub.PublishedDate)},
+		{"volume", pub.Volume},
+		{"number", pub.Issue},
+		{"pages", strings.Replace(pub.Page, "-", "--", 1)},
+		{"issn", pub.Issn},
+		{"doi", pub.Doi},
+		{"pmid", pub.Pubmed},
+		{"url", pub.PubmedURL},
+		{"abstract", pub.Abstract},
+	}
+	for _, f := range fields {
+		if len(f[1]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "  %s = {%s},\n", f[0], bibtexEscaper.Replace(f[1]))
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// Pub2RIS formats a publication in the RIS citation format
+func Pub2RIS(pub *Publication) string {
+	var buf bytes.Buffer
+	risLine(&buf, "TY", "JOUR")
+	for _, a := range pub.Authors {
+		risLine(&buf, "AU", bibtexAuthor(a))
+	}
+	risLine(&buf, "TI", pub.Title)
+	risLine(&buf, "JO", pub.Journal)
+	risLine(&buf, "PY", pubYear(pub.PublishedDate))
+	risLine(&buf, "DA", strings.Replace(pub.PublishedDate, "-", "/", -1))
+	risLine(&buf, "VL", pub.Volume)
+	risLine(&buf, "IS", pub.Issue)
+	pages := strings.SplitN(pub.Page, "-", 2)
+	risLine(&buf, "SP", pages[0])
+	if len(pages) == 2 {
+		risLine(&buf, "EP", pages[1])
+	}
+	risLine(&buf, "SN", pub.Issn)
+	risLine(&buf, "DO", pub.Doi)
+	risLine(&buf, "AN", pub.Pubmed)
+	risLine(&buf, "UR", pub.PubmedURL)
+	risLine(&buf, "AB", pub.Abstract)
+	buf.WriteString("ER  - \n")
+	return buf.String()
+}
+
+// risLine writes the tagged value on a single line, every line of RIS has
+// to start with a tag
+func risLine(buf *bytes.Buffer, tag, value string) {
+	value = strings.Join(strings.Fields(value), " ")
+	if len(value) == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "%s  - %s\n", tag, value)
+}
+
+func bibtexAuthor(a *Author) string {
+	if len(a.FirstName) > 0 {
+		return fmt.Sprintf("%s, %s", a.LastName, a.FirstName)
+	}
+	if len(a.LastName) > 0 {
+		return a.LastName
+	}
+	return a.FullName
+}
+
+func pubYear(date string) string {
+	if len(date) < 4 {
+		return date
+	}
+	return date[:4]
+}
@@ -0,0 +1,121 @@
+package kubeless
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"empty", "", jsonAPIMediaType},
+		{"anything", "*/*", jsonAPIMediaType},
+		{"exact", "application/x-bibtex", bibtexMediaType},
+		{"case insensitive", "Application/X-Research-Info-Systems", risMediaType},
+		{"highest quality", "application/json;q=0.5, application/x-bibtex;q=0.9", bibtexMediaType},
+		{"type wildcard", "text/html, application/*;q=0.8", jsonAPIMediaType},
+		{"unsupported", "text/html", ""},
+		{"refused", "application/vnd.api+json;q=0", ""},
+		{
+			"refused with wildcard",
+			"application/vnd.api+json;q=0, */*;q=0.5",
+			jsonMediaType,
+		},
+		{
+			"specific range wins",
+			"application/*;q=0.2, application/x-bibtex;q=0.9, */*;q=0.1",
+			bibtexMediaType,
+		},
+		{"all refused", "*/*;q=0", ""},
+		{"invalid quality ignored", "application/json;q=abc", jsonMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := negotiateMediaType(tt.accept, supportedMediaTypes)
+			if got != tt.want {
+				t.Errorf("negotiateMediaType(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPub2Bibtex(t *testing.T) {
+	tests := []struct {
+		name  string
+		pub   *Publication
+		field string
+	}{
+		{
+			"plain",
+			&Publication{Title: "Cell motility"},
+			"  title = {Cell motility},\n",
+		},
+		{
+			"unbalanced braces",
+			&Publication{Title: "The {cAMP relay"},
+			"  title = {The \\textbraceleft cAMP relay},\n",
+		},
+		{
+			"closing brace",
+			&Publication{Abstract: "ends} here"},
+			"  abstract = {ends\\textbraceright  here},\n",
+		},
+		{
+			"percent",
+			&Publication{Abstract: "50% of cells"},
+			"  abstract = {50\\% of cells},\n",
+		},
+		{
+			"backslash",
+			&Publication{Title: `a\b`},
+			"  title = {a\\textbackslash b},\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Pub2Bibtex("1", tt.pub)
+			if !strings.Contains(got, tt.field) {
+				t.Errorf("Pub2Bibtex() = %q, want field %q", got, tt.field)
+			}
+			if strings.Count(got, "{") != strings.Count(got, "}") {
+				t.Errorf("Pub2Bibtex() = %q has unbalanced braces", got)
+			}
+		})
+	}
+}
+
+func TestPub2RIS(t *testing.T) {
+	tests := []struct {
+		name string
+		pub  *Publication
+		line string
+	}{
+		{"plain", &Publication{Title: "Cell motility"}, "TI  - Cell motility\n"},
+		{
+			"newlines",
+			&Publication{Abstract: "First line.\nSecond\r\nline."},
+			"AB  - First line. Second line.\n",
+		},
+		{"carriage return", &Publication{Title: "a\rb"}, "TI  - a b\n"},
+		{"only whitespace", &Publication{Title: "\n"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Pub2RIS(tt.pub)
+			if !strings.Contains(got, tt.line) {
+				t.Errorf("Pub2RIS() = %q, want line %q", got, tt.line)
+			}
+			for _, l := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+				if len(l) < 6 || l[2:6] != "  - " {
+					t.Errorf("line %q does not start with a tag", l)
+				}
+			}
+			if strings.Contains(got, "\r") {
+				t.Errorf("Pub2RIS() = %q has a carriage return", got)
+			}
+		})
+	}
+}
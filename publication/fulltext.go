@@ -0,0 +1,424 @@
+package kubeless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"github.com/spacemonkeygo/errors/errhttp"
+)
+
+const jatsMediaType = "application/xml"
+
+// fullTextMediaTypes are the representations of a full text article, the
+// parsed JSON:API document is the default
+var fullTextMediaTypes = []string{
+	jsonAPIMediaType,
+	jatsMediaType,
+	"text/xml",
+}
+
+type FullTextJsonAPI struct {
+	Data  *FullTextData `json:"data"`
+	Links *Links        `json:"links"`
+}
+
+type FullTextData struct {
+	Type       string    `json:"type"`
+	ID         string    `json:"id"`
+	Attributes *FullText `json:"attributes"`
+}
+
+// FullText is the parsed content of an open access article
+type FullText struct {
+	Pmcid      string       `json:"pmcid"`
+	Title      string       `json:"title"`
+	Abstract   string       `json:"abstract"`
+	Sections   []*Section   `json:"sections"`
+	Figures    []*Figure    `json:"figures"`
+	References []*Reference `json:"references"`
+}
+
+type Section struct {
+	ID         string     `json:"id,omitempty"`
+	Title      string     `json:"title"`
+	Paragraphs []string   `json:"paragraphs,omitempty"`
+	Sections   []*Section `json:"sections,omitempty"`
+}
+
+type Figure struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Title   string `json:"title,omitempty"`
+	Caption string `json:"caption"`
+	Graphic string `json:"graphic,omitempty"`
+}
+
+type Reference struct {
+	ID      string   `json:"id"`
+	Label   string   `json:"label,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+	Title   string   `json:"title,omitempty"`
+	Source  string   `json:"source,omitempty"`
+	Year    string   `json:"year,omitempty"`
+	Volume  string   `json:"volume,omitempty"`
+	Pages   string   `json:"pages,omitempty"`
+	Pubmed  string   `json:"pubmed,omitempty"`
+	Doi     string   `json:"doi,omitempty"`
+}
+
+// jatsText collects the character data of an element and its inline
+// children, floating figures and tables are left out.
+type jatsText string
+
+func (t *jatsText) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var buf bytes.Buffer
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			if el.Name.Local == "fig" || el.Name.Local == "table-wrap" {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			depth++
+		case xml.CharData:
+			buf.Write(el)
+		case xml.EndElement:
+			if depth == 0 {
+				*t = jatsText(strings.Join(strings.Fields(buf.String()), " "))
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+type jatsSection struct {
+	ID         string        `xml:"id,attr"`
+	Title      jatsText      `xml:"title"`
+	Paragraphs []jatsText    `xml:"p"`
+	Sections   []jatsSection `xml:"sec"`
+}
+
+type jatsName struct {
+	Surname    string `xml:"surname"`
+	GivenNames string `xml:"given-names"`
+}
+
+type jatsCitation struct {
+	Names        []jatsName `xml:"person-group>name"`
+	ArticleTitle jatsText   `xml:"article-title"`
+	Source       jatsText   `xml:"source"`
+	Year         string     `xml:"year"`
+	Volume       string     `xml:"volume"`
+	FirstPage    string     `xml:"fpage"`
+	LastPage     string     `xml:"lpage"`
+	PubIds       []struct {
+		Type  string `xml:"pub-id-type,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"pub-id"`
+}
+
+type jatsRef struct {
+	ID               string        `xml:"id,attr"`
+	Label            string        `xml:"label"`
+	ElementCitation  *jatsCitation `xml:"element-citation"`
+	MixedCitation    *jatsCitation `xml:"mixed-citation"`
+	NlmCitation      *jatsCitation `xml:"nlm-citation"`
+	CitationFallback *jatsCitation `xml:"citation"`
+}
+
+type jatsFigure struct {
+	ID      string   `xml:"id,attr"`
+	Label   jatsText `xml:"label"`
+	Caption struct {
+		Title      jatsText   `xml:"title"`
+		Paragraphs []jatsText `xml:"p"`
+	} `xml:"caption"`
+	Graphic struct {
+		Href string `xml:"http://www.w3.org/1999/xlink href,attr"`
+	} `xml:"graphic"`
+}
+
+type jatsArticle struct {
+	Title      jatsText      `xml:"front>article-meta>title-group>article-title"`
+	Abstract   jatsText      `xml:"front>article-meta>abstract"`
+	Sections   []jatsSection `xml:"body>sec"`
+	Paragraphs []jatsText    `xml:"body>p"`
+	References []jatsRef     `xml:"back>ref-list>ref"`
+}
+
+func fullTextHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) (string, error) {
+	mt := negotiateMediaType(r.Header.Get("Accept"), fullTextMediaTypes)
+	if len(mt) == 0 {
+		json, status, err := shared.JSONAPIError(
+			apherror.ErrNotAcceptable.New(
+				"none of %s is supported, use one of %s",
+				r.Header.Get("Accept"),
+				strings.Join(fullTextMediaTypes, ", "),
+			),
+		)
+		w.WriteHeader(status)
+		return json, err
+	}
+	rkey := fmt.Sprintf("%s/%s/fulltext", REDIS_KEY, id)
+	if mt != jsonAPIMediaType {
+		b, err := cachedFetch(ctx, rkey, pubCacheTTL, func(ctx context.Context) ([]byte, error) {
+			return fetchFullText(ctx, id)
+		})
+		if err != nil {
+			return errorResponse(w, err)
+		}
+		w.Header().Set("Content-Type", mt)
+		w.Header().Add("Vary", "Accept")
+		return string(b), nil
+	}
+	// the parsed article is cached on its own so that the XML is not
+	// parsed on every request
+	b, err := cachedFetch(ctx, rkey+".json", pubCacheTTL, func(ctx context.Context) ([]byte, error) {
+		xb, err := cachedFetch(ctx, rkey, pubCacheTTL, func(ctx context.Context) ([]byte, error) {
+			return fetchFullText(ctx, id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		ft, err := ParseJATS(bytes.NewReader(xb))
+		if err != nil {
+			return nil, apherror.Errhttp.NewClass(
+				http.StatusText(http.StatusInternalServerError),
+				errhttp.SetStatusCode(http.StatusInternalServerError),
+			).New("error in parsing full text %s", err.Error())
+		}
+		return json.Marshal(ft)
+	})
+	if err != nil {
+		return errorResponse(w, err)
+	}
+	return writeFullText(w, r, id, b)
+}
+
+// fetchFullText retrieves the JATS XML of an open access article from
+// Europe PMC
+func fetchFullText(ctx context.Context, id string) ([]byte, error) {
+	epmc := &EuroPMC{}
+	err := fetchEuroPMC(
+		ctx,
+		fmt.Sprintf(
+			"%s?format=json&resultType=core&query=ext_id:%s",
+			EuroPMCBaseURL+"/search",
+			id,
+		),
+		epmc,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(epmc.ResultList.Result) == 0 {
+		return nil, apherror.ErrNotFound.New("publication %s is not found", id)
+	}
+	result := epmc.ResultList.Result[0]
+	if result.InEPMC != "Y" || result.IsOpenAccess != "Y" || len(result.Pmcid) == 0 {
+		return nil, apherror.ErrNotFound.New(
+			"publication %s has no open access full text",
+			id,
+		)
+	}
+	return fetchUpstream(
+		ctx,
+		fmt.Sprintf("%s/%s/fullTextXML", EuroPMCBaseURL, result.Pmcid),
+	)
+}
+
+// writeFullText sends the cached parsed article as a JSON:API document
+func writeFullText(w http.ResponseWriter, r *http.Request, id string, b []byte) (string, error) {
+	ft := &FullText{}
+	if err := json.Unmarshal(b, ft); err != nil {
+		json, status, err := shared.JSONAPIError(
+			apherror.ErrJSONEncoding.New(
+				"error in decoding cached full text %s",
+				err.Error(),
+			),
+		)
+		w.WriteHeader(status)
+		return json, err
+	}
+	ct, err := json.Marshal(&FullTextJsonAPI{
+		Data: &FullTextData{
+			Type:       "fulltexts",
+			ID:         id,
+			Attributes: ft,
+		},
+		Links: &Links{
+			Self: generateLink(r),
+		},
+	})
+	if err != nil {
+		json, status, err := shared.JSONAPIError(
+			apherror.ErrStructMarshal.New(
+				"error in making final response %s",
+				err.Error(),
+			),
+		)
+		w.WriteHeader(status)
+		return json, err
+	}
+	w.Header().Add("Vary", "Accept")
+	return string(ct), nil
+}
+
+// ParseJATS extracts the sections, figures and references from a JATS XML
+// full text article
+func ParseJATS(r io.Reader) (*FullText, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	article := &jatsArticle{}
+	if err := newJATSDecoder(b).Decode(article); err != nil {
+		return nil, fmt.Errorf("error in decoding article %s", err)
+	}
+	ft := &FullText{
+		Title:      string(article.Title),
+		Abstract:   string(article.Abstract),
+		Sections:   make([]*Section, 0),
+		Figures:    make([]*Figure, 0),
+		References: make([]*Reference, 0),
+	}
+	if len(article.Paragraphs) > 0 {
+		ft.Sections = append(ft.Sections, jats2Section(jatsSection{
+			Paragraphs: article.Paragraphs,
+		}))
+	}
+	for _, s := range article.Sections {
+		ft.Sections = append(ft.Sections, jats2Section(s))
+	}
+	for _, ref := range article.References {
+		ft.References = append(ft.References, jats2Reference(ref))
+	}
+	// figures could be nested anywhere in the body or in a floats group,
+	// so they are collected in a separate pass
+	d := newJATSDecoder(b)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error in reading figures %s", err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch el.Name.Local {
+		case "article-id":
+			for _, a := range el.Attr {
+				if a.Name.Local == "pub-id-type" && a.Value == "pmcid" {
+					var pmcid string
+					if err := d.DecodeElement(&pmcid, &el); err != nil {
+						return nil, err
+					}
+					ft.Pmcid = strings.TrimSpace(pmcid)
+				}
+			}
+		case "fig":
+			fig := &jatsFigure{}
+			if err := d.DecodeElement(fig, &el); err != nil {
+				return nil, fmt.Errorf("error in decoding figure %s", err)
+			}
+			ft.Figures = append(ft.Figures, &Figure{
+				ID:      fig.ID,
+				Label:   string(fig.Label),
+				Title:   string(fig.Caption.Title),
+				Caption: joinJATSText(fig.Caption.Paragraphs),
+				Graphic: fig.Graphic.Href,
+			})
+		}
+	}
+	return ft, nil
+}
+
+func newJATSDecoder(b []byte) *xml.Decoder {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	return d
+}
+
+func joinJATSText(txt []jatsText) string {
+	var s []string
+	for _, t := range txt {
+		s = append(s, string(t))
+	}
+	return strings.Join(s, " ")
+}
+
+func jats2Section(s jatsSection) *Section {
+	sec := &Section{ID: s.ID, Title: string(s.Title)}
+	for _, p := range s.Paragraphs {
+		if len(p) > 0 {
+			sec.Paragraphs = append(sec.Paragraphs, string(p))
+		}
+	}
+	for _, sub := range s.Sections {
+		sec.Sections = append(sec.Sections, jats2Section(sub))
+	}
+	return sec
+}
+
+func jats2Reference(ref jatsRef) *Reference {
+	r := &Reference{ID: ref.ID, Label: ref.Label}
+	var cit *jatsCitation
+	for _, c := range []*jatsCitation{
+		ref.ElementCitation,
+		ref.MixedCitation,
+		ref.NlmCitation,
+		ref.CitationFallback,
+	} {
+		if c != nil {
+			cit = c
+			break
+		}
+	}
+	if cit == nil {
+		return r
+	}
+	for _, n := range cit.Names {
+		r.Authors = append(
+			r.Authors,
+			strings.TrimSpace(fmt.Sprintf("%s %s", n.Surname, n.GivenNames)),
+		)
+	}
+	r.Title = string(cit.ArticleTitle)
+	r.Source = string(cit.Source)
+	r.Year = cit.Year
+	r.Volume = cit.Volume
+	r.Pages = cit.FirstPage
+	if len(cit.LastPage) > 0 {
+		r.Pages = fmt.Sprintf("%s-%s", cit.FirstPage, cit.LastPage)
+	}
+	for _, id := range cit.PubIds {
+		switch id.Type {
+		case "pmid":
+			r.Pubmed = id.Value
+		case "doi":
+			r.Doi = id.Value
+		}
+	}
+	return r
+}
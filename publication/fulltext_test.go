@@ -0,0 +1,109 @@
+package kubeless
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const jatsArticleXML = `<?xml version="1.0"?>
+<article xmlns:xlink="http://www.w3.org/1999/xlink">
+<front><article-meta>
+	<article-id pub-id-type="pmid">123</article-id>
+	<article-id pub-id-type="pmcid">PMC456</article-id>
+	<title-group><article-title>Cell <italic>motility</italic> in
+	Dictyostelium</article-title></title-group>
+	<abstract><p>Cells move&nbsp;fast.</p></abstract>
+</article-meta></front>
+<body>
+	<p>Lead paragraph.</p>
+	<sec id="s1">
+		<title>Results</title>
+		<p>Amoebae aggregate <xref ref-type="bibr" rid="r1">[1]</xref>.</p>
+		<fig id="f1">
+			<label>Figure 1</label>
+			<caption><title>Aggregation</title><p>Streams of cells.</p></caption>
+			<graphic xlink:href="f1.jpg"/>
+		</fig>
+		<sec id="s1.1"><title>Streaming</title><p>cAMP relay.</p></sec>
+	</sec>
+</body>
+<back><ref-list>
+	<ref id="r1"><label>1</label><element-citation>
+		<person-group><name><surname>Doe</surname><given-names>J</given-names></name></person-group>
+		<article-title>Chemotaxis</article-title><source>Cell</source>
+		<year>2001</year><volume>5</volume><fpage>10</fpage><lpage>12</lpage>
+		<pub-id pub-id-type="pmid">999</pub-id><pub-id pub-id-type="doi">10.1/x</pub-id>
+	</element-citation></ref>
+	<ref id="r2"><mixed-citation><source>Nature</source><year>1999</year></mixed-citation></ref>
+</ref-list></back>
+</article>`
+
+func TestParseJATS(t *testing.T) {
+	ft, err := ParseJATS(strings.NewReader(jatsArticleXML))
+	if err != nil {
+		t.Fatalf("ParseJATS() error %s", err)
+	}
+	want := &FullText{
+		Pmcid:    "PMC456",
+		Title:    "Cell motility in Dictyostelium",
+		Abstract: "Cells move fast.",
+		Sections: []*Section{
+			{Paragraphs: []string{"Lead paragraph."}},
+			{
+				ID:         "s1",
+				Title:      "Results",
+				Paragraphs: []string{"Amoebae aggregate [1]."},
+				Sections: []*Section{
+					{ID: "s1.1", Title: "Streaming", Paragraphs: []string{"cAMP relay."}},
+				},
+			},
+		},
+		Figures: []*Figure{{
+			ID:      "f1",
+			Label:   "Figure 1",
+			Title:   "Aggregation",
+			Caption: "Streams of cells.",
+			Graphic: "f1.jpg",
+		}},
+		References: []*Reference{
+			{
+				ID:      "r1",
+				Label:   "1",
+				Authors: []string{"Doe J"},
+				Title:   "Chemotaxis",
+				Source:  "Cell",
+				Year:    "2001",
+				Volume:  "5",
+				Pages:   "10-12",
+				Pubmed:  "999",
+				Doi:     "10.1/x",
+			},
+			{ID: "r2", Source: "Nature", Year: "1999"},
+		},
+	}
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"pmcid", ft.Pmcid, want.Pmcid},
+		{"title", ft.Title, want.Title},
+		{"abstract", ft.Abstract, want.Abstract},
+		{"sections", ft.Sections, want.Sections},
+		{"figures", ft.Figures, want.Figures},
+		{"references", ft.References, want.References},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %#v, want %#v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJATSInvalid(t *testing.T) {
+	if _, err := ParseJATS(strings.NewReader("not xml")); err == nil {
+		t.Error("ParseJATS() of invalid XML is expected to fail")
+	}
+}
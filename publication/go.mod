@@ -4,9 +4,11 @@ go 1.13
 
 require (
 	github.com/dictyBase/apihelpers v0.0.0-20180801151846-aa9d10182786
+	github.com/dictybase-playground/kubeless-gofn/shared v0.0.0
 	github.com/fatih/structs v1.0.0
-	github.com/go-redis/redis v6.13.2+incompatible
-	github.com/gomodule/redigo v2.0.0+incompatible
 	github.com/kubeless/kubeless v1.0.7
 	github.com/spacemonkeygo/errors v0.0.0-20171212215202-9064522e9fd1
+	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
 )
+
+replace github.com/dictybase-playground/kubeless-gofn/shared => ../shared
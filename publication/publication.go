@@ -1,30 +1,42 @@
 package kubeless
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictybase-playground/kubeless-gofn/shared"
 	"github.com/fatih/structs"
 	"github.com/kubeless/kubeless/pkg/functions"
-	"github.com/spacemonkeygo/errors"
-	"github.com/spacemonkeygo/errors/errhttp"
 )
 
 var (
 	pubRegxp      = regexp.MustCompile(`^/(\d+)$`)
-	titleErrKey   = errors.GenSym()
-	pointerErrKey = errors.GenSym()
-	paramErrKey   = errors.GenSym()
+	linkRegxp     = regexp.MustCompile(`^/(\d+)/(citations|references)$`)
+	fullTextRegxp = regexp.MustCompile(`^/(\d+)/fulltext$`)
 )
 
-const REDIS_KEY = "PUBLICATION_KEY"
+const (
+	REDIS_KEY      = "PUBLICATION_KEY"
+	EuroPMCBaseURL = "https://www.ebi.ac.uk/europepmc/webservices/rest"
+	// redis channel where the replicas announce the keys that has to be
+	// dropped from their in memory cache
+	INVALIDATION_CHANNEL = "PUBLICATION_KEY:invalidate"
+	// default timeout of kubeless functions
+	defaultTimeout = 180 * time.Second
+	pubCacheTTL    = 30 * 24 * time.Hour
+	// number of entries in the in memory cache
+	defaultMemoryCacheSize = 1000
+	// maximum duration of an entry in the in memory cache in front of redis
+	defaultL1TTL = 5 * time.Minute
+)
 
 type PubJsonAPI struct {
 	Data  *PubData `json:"data"`
@@ -144,6 +156,7 @@ type EuroPMC struct {
 			Language    string `json:"language"`
 			NihAuthMan  string `json:"nihAuthMan"`
 			PageInfo    string `json:"pageInfo"`
+			Pmcid       string `json:"pmcid"`
 			Pmid        string `json:"pmid"`
 			PubModel    string `json:"pubModel"`
 			PubTypeList struct {
@@ -157,35 +170,50 @@ type EuroPMC struct {
 	Version string `json:"version"`
 }
 
-func getRedisConnection() Cacher {
-	var cache Cacher
-	rhost := os.Getenv("REDIS_MASTER_SERVICE_HOST")
-	rport := os.Getenv("REDIS_MASTER_SERVICE_PORT")
-	if len(rhost) > 0 && len(rport) > 0 {
-		shost := os.Getenv("REDIS_SLAVE_SERVICE_HOST")
-		sport := os.Getenv("REDIS_SLAVE_SERVICE_PORT")
-		if len(shost) > 0 && len(sport) > 0 {
-			cache = NewRedisReplicationCache(
-				fmt.Sprintf("%s:%s", rhost, rport),
-				fmt.Sprintf("%s:%s", shost, sport),
-			)
-			log.Println("connected to redis with replication")
-			return cache
+// getCache returns the cache configured from the environment. Up to
+// MEMORY_CACHE_SIZE entries are kept in memory, either in front of redis
+// for CACHE_L1_TTL or as the only cache when redis is absent. A size of
+// zero disables the in memory cache. Values of at least
+// REDIS_COMPRESS_MIN_SIZE bytes are stored compressed in redis unless
+// REDIS_COMPRESSION is false.
+func getCache() shared.Cacher {
+	size := shared.IntEnv("MEMORY_CACHE_SIZE", defaultMemoryCacheSize)
+	rcache, err := shared.NewRedisCacher(shared.RedisConfigFromEnv())
+	if err != nil {
+		if err != shared.ErrNoBackend {
+			log.Printf("error in connecting to redis %s", err)
 		}
-		log.Println("connected to redis master")
-		cache = NewRedisCache(fmt.Sprintf("%s:%s", rhost, rport))
+		rcache = nil
+	}
+	switch {
+	case size == 0:
+		return rcache
+	case rcache == nil:
+		log.Printf("using in memory cache of %d entries", size)
+		return shared.NewMemoryCache(size)
 	}
-	return cache
+	log.Printf("using in memory cache of %d entries in front of redis", size)
+	return shared.NewTieredCache(
+		shared.NewMemoryCache(size),
+		rcache,
+		shared.DurationEnv("CACHE_L1_TTL", defaultL1TTL),
+		INVALIDATION_CHANNEL,
+	)
 }
 
-var cache = getRedisConnection()
+var cache = getCache()
 
 func Handler(event functions.Event, ctx functions.Context) (string, error) {
 	r := event.Extensions.Request
 	w := event.Extensions.Response
-	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.Header().Set("Content-Type", jsonAPIMediaType)
+	rctx, cancel := requestContext(event, ctx)
+	defer cancel()
+	if m := cacheAdminRegxp.FindStringSubmatch(r.URL.Path); len(m) > 0 {
+		return cacheAdminHandler(rctx, w, r, event.Data, m)
+	}
 	if r.Method != "GET" {
-		json, status, err := JSONAPIError(
+		json, status, err := shared.JSONAPIError(
 			apherror.ErrMethodNotAllowed.New(
 				"%s not allowed",
 				r.Method,
@@ -193,12 +221,33 @@ func Handler(event functions.Event, ctx functions.Context) (string, error) {
 		w.WriteHeader(status)
 		return json, err
 	}
-	m := pubRegxp.FindStringSubmatch(r.URL.Path)
-	if len(m) == 0 {
-		json, status, err := JSONAPIError(
-			apherror.ErrNotFound.New(
-				"no route for %s",
-				generateLink(r),
+	if m := pubRegxp.FindStringSubmatch(r.URL.Path); len(m) > 0 {
+		return publicationHandler(rctx, w, r, m[1])
+	}
+	if m := linkRegxp.FindStringSubmatch(r.URL.Path); len(m) > 0 {
+		return linkedPublicationHandler(rctx, w, r, m[1], m[2])
+	}
+	if m := fullTextRegxp.FindStringSubmatch(r.URL.Path); len(m) > 0 {
+		return fullTextHandler(rctx, w, r, m[1])
+	}
+	json, status, err := shared.JSONAPIError(
+		apherror.ErrNotFound.New(
+			"no route for %s",
+			generateLink(r),
+		),
+	)
+	w.WriteHeader(status)
+	return json, err
+}
+
+func publicationHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) (string, error) {
+	mt := negotiateMediaType(r.Header.Get("Accept"), supportedMediaTypes)
+	if len(mt) == 0 {
+		json, status, err := shared.JSONAPIError(
+			apherror.ErrNotAcceptable.New(
+				"none of %s is supported, use one of %s",
+				r.Header.Get("Accept"),
+				strings.Join(supportedMediaTypes, ", "),
 			),
 		)
 		w.WriteHeader(status)
@@ -208,76 +257,90 @@ func Handler(event functions.Event, ctx functions.Context) (string, error) {
 		"%s%s",
 		REDIS_KEY, r.URL.Path,
 	)
-	if cache != nil {
-		if cache.IsExist(rkey) {
-			v, err := cache.Get(rkey)
-			if err == nil {
-				log.Printf("got key %s from cache", rkey)
-				return string(v), nil
-			}
-			log.Printf("error in getting existing key %s %s", rkey, err)
-		}
-	} else {
-		log.Println("no redis cache")
-	}
-	url := fmt.Sprintf(
-		"%s?format=json&resultType=core&query=ext_id:%s",
-		"https://www.ebi.ac.uk/europepmc/webservices/rest/search",
-		m[1],
-	)
-	res, err := http.Get(url)
+	self := generateLink(r)
+	b, err := cachedFetch(ctx, rkey, pubCacheTTL, func(ctx context.Context) ([]byte, error) {
+		return fetchPublication(ctx, id, self)
+	})
 	if err != nil {
-		json, _, err := JSONAPIError(
-			apherror.Errhttp.NewClass(
-				res.Status,
-				errhttp.SetStatusCode(res.StatusCode),
-			).New("error %s in fetching %s", err.Error(), m[1]),
-		)
-		w.WriteHeader(res.StatusCode)
-		return json, err
+		return errorResponse(w, err)
 	}
-	defer res.Body.Close()
+	return writePublication(w, b, mt)
+}
+
+// fetchPublication retrieves the publication from Europe PMC and makes
+// its JSON:API representation
+func fetchPublication(ctx context.Context, id, self string) ([]byte, error) {
 	epmc := &EuroPMC{}
-	err = json.NewDecoder(res.Body).Decode(epmc)
+	err := fetchEuroPMC(
+		ctx,
+		fmt.Sprintf(
+			"%s?format=json&resultType=core&query=ext_id:%s",
+			EuroPMCBaseURL+"/search",
+			id,
+		),
+		epmc,
+	)
 	if err != nil {
-		json, _, err := JSONAPIError(
-			apherror.Errhttp.NewClass(
-				http.StatusText(http.StatusInternalServerError),
-				errhttp.SetStatusCode(http.StatusInternalServerError),
-			).New("error in decoding body %s", err.Error()),
-		)
-		w.WriteHeader(http.StatusInternalServerError)
-		return json, err
+		return nil, err
+	}
+	if len(epmc.ResultList.Result) == 0 {
+		return nil, apherror.ErrNotFound.New("publication %s is not found", id)
 	}
 	b, err := json.Marshal(&PubJsonAPI{
 		Data: &PubData{
 			Type:       "publications",
-			ID:         m[1],
+			ID:         id,
 			Attributes: EuroPMC2Pub(epmc),
 		},
 		Links: &Links{
-			Self: generateLink(r),
+			Self: self,
 		},
 	})
 	if err != nil {
-		json, status, err := JSONAPIError(
+		return nil, apherror.ErrStructMarshal.New(
+			"error in making final response %s",
+			err.Error(),
+		)
+	}
+	return b, nil
+}
+
+// writePublication sends the publication in the negotiated media type
+func writePublication(w http.ResponseWriter, b []byte, mt string) (string, error) {
+	ct, err := renderPublication(b, mt)
+	if err != nil {
+		json, status, err := shared.JSONAPIError(
 			apherror.ErrStructMarshal.New(
-				"error in making final response %s",
-				err.Error(),
+				"error in rendering %s %s",
+				mt, err.Error(),
 			),
 		)
 		w.WriteHeader(status)
 		return json, err
+	}
+	w.Header().Set("Content-Type", mt)
+	w.Header().Add("Vary", "Accept")
+	return ct, nil
+}
 
+// requestContext derives the context for handling the request, its deadline
+// is kept short of the function timeout so that a slow upstream could
+// still be reported before kubeless kills the function.
+func requestContext(event functions.Event, fctx functions.Context) (context.Context, context.CancelFunc) {
+	parent := context.Background()
+	if event.Extensions.Context != nil {
+		parent = event.Extensions.Context
 	}
-	if cache != nil {
-		if err := cache.Set(rkey, b, 30*24*time.Hour); err != nil {
-			log.Printf("error in setting key %s %s", rkey, err)
-		} else {
-			log.Printf("stored key %s in cache", rkey)
-		}
+	return context.WithTimeout(parent, functionTimeout(fctx.Timeout)*9/10)
+}
+
+// functionTimeout parses the timeout of the function in seconds, as given
+// by kubeless in FUNC_TIMEOUT, the default is used if it is not valid
+func functionTimeout(v string) time.Duration {
+	if t, err := strconv.Atoi(v); err == nil && t > 0 {
+		return time.Duration(t) * time.Second
 	}
-	return string(b), nil
+	return defaultTimeout
 }
 
 func generateLink(r *http.Request) string {
@@ -289,35 +352,6 @@ func generateLink(r *http.Request) string {
 	)
 }
 
-//JSONAPIError generate JSONAPI formatted http error from an error object
-func JSONAPIError(err error) (string, int, error) {
-	status := errhttp.GetStatusCode(err, http.StatusInternalServerError)
-	title, _ := errors.GetData(err, titleErrKey).(string)
-	jsnErr := apherror.Error{
-		Status: strconv.Itoa(status),
-		Title:  title,
-		Detail: errhttp.GetErrorBody(err),
-		Meta: map[string]interface{}{
-			"creator": "kubeless gofn error",
-		},
-	}
-	errSource := new(apherror.ErrorSource)
-	pointer, ok := errors.GetData(err, pointerErrKey).(string)
-	if ok {
-		errSource.Pointer = pointer
-	}
-	param, ok := errors.GetData(err, paramErrKey).(string)
-	if ok {
-		errSource.Parameter = param
-	}
-	jsnErr.Source = errSource
-	ct, encErr := json.Marshal(apherror.HTTPError{Errors: []apherror.Error{jsnErr}})
-	if encErr != nil {
-		return "", http.StatusInternalServerError, encErr
-	}
-	return string(ct), status, nil
-}
-
 func EuroPMC2Pub(pmc *EuroPMC) *Publication {
 	if len(pmc.ResultList.Result) < 1 {
 		log.Println("no results found for publication")
@@ -0,0 +1,155 @@
+package kubeless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/dictybase-playground/kubeless-gofn/shared"
+	"github.com/spacemonkeygo/errors"
+	"github.com/spacemonkeygo/errors/errhttp"
+)
+
+var (
+	// ErrUpstream is the parent class of all errors in talking to
+	// Europe PMC
+	ErrUpstream = newUpstreamClass(
+		apherror.Errhttp,
+		"Upstream error",
+		http.StatusBadGateway,
+	)
+	// ErrUpstreamUnavailable is for network errors in reaching Europe PMC
+	ErrUpstreamUnavailable = newUpstreamClass(
+		ErrUpstream,
+		"Upstream unavailable",
+		http.StatusBadGateway,
+	)
+	// ErrUpstreamTimeout is for requests to Europe PMC that timed out
+	ErrUpstreamTimeout = newUpstreamClass(
+		ErrUpstream,
+		"Upstream timeout",
+		http.StatusGatewayTimeout,
+	)
+	// ErrUpstreamStatus is for non successful responses from Europe PMC,
+	// the upstream status code is kept in the error data
+	ErrUpstreamStatus = newUpstreamClass(
+		ErrUpstream,
+		"Upstream error response",
+		http.StatusBadGateway,
+	)
+	// ErrUpstreamDecode is for Europe PMC responses that could not be
+	// decoded
+	ErrUpstreamDecode = newUpstreamClass(
+		ErrUpstream,
+		"Upstream response decoding error",
+		http.StatusBadGateway,
+	)
+)
+
+func newUpstreamClass(parent *errors.ErrorClass, title string, code int) *errors.ErrorClass {
+	return parent.NewClass(
+		title,
+		errhttp.SetStatusCode(code),
+		errors.SetData(shared.TitleErrKey, title),
+	)
+}
+
+// fetchUpstream retrieves the body of a successful response from the
+// endpoint, any failure is returned as one of the ErrUpstream errors.
+func fetchUpstream(ctx context.Context, endpoint string) ([]byte, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, ErrUpstreamUnavailable.New(
+			"error in making request for %s %s",
+			endpoint, err,
+		)
+	}
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		if isTimeout(ctx, err) {
+			return nil, ErrUpstreamTimeout.New(
+				"timed out in fetching %s %s",
+				endpoint, err,
+			)
+		}
+		return nil, ErrUpstreamUnavailable.New(
+			"error in fetching %s %s",
+			endpoint, err,
+		)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, ErrUpstreamStatus.NewWith(
+			fmt.Sprintf(
+				"got %s in fetching %s",
+				res.Status, endpoint,
+			),
+			errhttp.SetStatusCode(upstreamStatusCode(res.StatusCode)),
+			errors.SetData(
+				shared.MetaErrKey,
+				map[string]interface{}{"upstream_status": res.StatusCode},
+			),
+		)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		if isTimeout(ctx, err) {
+			return nil, ErrUpstreamTimeout.New(
+				"timed out in reading response of %s %s",
+				endpoint, err,
+			)
+		}
+		return nil, ErrUpstreamUnavailable.New(
+			"error in reading response of %s %s",
+			endpoint, err,
+		)
+	}
+	return b, nil
+}
+
+// fetchEuroPMC retrieves and decodes a JSON response from Europe PMC
+func fetchEuroPMC(ctx context.Context, endpoint string, v interface{}) error {
+	b, err := fetchUpstream(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrUpstreamDecode.New(
+			"error in decoding response of %s %s",
+			endpoint, err,
+		)
+	}
+	return nil
+}
+
+func isTimeout(ctx context.Context, err error) bool {
+	if ctx.Err() == context.DeadlineExceeded {
+		return true
+	}
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
+
+// upstreamStatusCode maps the status code of an upstream response to the
+// one that is sent to the client
+func upstreamStatusCode(code int) int {
+	switch code {
+	case http.StatusNotFound:
+		return http.StatusNotFound
+	case http.StatusGatewayTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// errorResponse writes the JSON:API formatted error along with its status
+func errorResponse(w http.ResponseWriter, err error) (string, error) {
+	json, status, errn := shared.JSONAPIError(err)
+	w.WriteHeader(status)
+	return json, errn
+}
@@ -0,0 +1,438 @@
+package shared
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrNoBackend is returned when redis is not configured
+var ErrNoBackend = errors.New("no redis backend available")
+
+// Cacher is a key value cache with expiring keys
+type Cacher interface {
+	// Get returns the value of the key, found is false for a key that is
+	// not in the cache and err is only for the failures of the cache
+	Get(context.Context, string) (value []byte, found bool, err error)
+	// GetWithTTL is Get along with the time the key has left before it
+	// expires, it is zero for a key without expiry
+	GetWithTTL(context.Context, string) (value []byte, ttl time.Duration, found bool, err error)
+	Set(context.Context, string, []byte, time.Duration) error
+	Delete(context.Context, string) error
+	ClearAll(context.Context, string) (int64, error)
+}
+
+// Locker is implemented by the Cacher that could hold a lock shared
+// by all the replicas of the function
+type Locker interface {
+	// Lock acquires the lock for the given key and token if it is not
+	// held already, the lock expires after the given duration
+	Lock(context.Context, string, string, time.Duration) (bool, error)
+	// Unlock releases the lock if it is still held by the token
+	Unlock(context.Context, string, string) error
+	// Extend resets the expiry of the lock to the given duration if it is
+	// still held by the token, false is returned if it is not
+	Extend(context.Context, string, string, time.Duration) (bool, error)
+}
+
+const (
+	// number of keys fetched in every SCAN iteration of ClearAll
+	scanCount = 500
+	// wait before subscribing again after losing the pubsub connection
+	resubscribeWait = 2 * time.Second
+)
+
+// unlockScript deletes the lock only if it is owned by the token
+const unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+// extendScript sets the expiry of the lock only if it is owned by the token
+const extendScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+// NewRedisCacher returns the Cacher for the redis deployment of the
+// config, the values are compressed if it is enabled. ErrNoBackend is
+// returned if redis is not configured.
+func NewRedisCacher(c *RedisConfig) (Cacher, error) {
+	var cache Cacher
+	switch c.Mode {
+	case ModeSentinel:
+		if len(c.SentinelMaster) == 0 || len(c.SentinelAddrs) == 0 {
+			return nil, fmt.Errorf("REDIS_SENTINEL_MASTER and REDIS_SENTINEL_ADDRS are needed for sentinel")
+		}
+		log.Printf("connected to redis master %s through sentinel", c.SentinelMaster)
+		cache = NewRedisSentinelCache(c.SentinelMaster, c.SentinelAddrs, c.Options)
+	case ModeCluster:
+		if len(c.ClusterAddrs) == 0 {
+			return nil, fmt.Errorf("REDIS_CLUSTER_ADDRS is needed for cluster")
+		}
+		log.Println("connected to redis cluster")
+		cache = NewRedisClusterCache(c.ClusterAddrs, c.Options)
+	default:
+		switch {
+		case len(c.Master) == 0:
+			return nil, ErrNoBackend
+		case len(c.Slave) > 0:
+			log.Println("connected to redis with replication")
+			cache = NewRedisReplicationCache(c.Master, c.Slave, c.ReadYourWrites, c.Options)
+		default:
+			log.Println("connected to redis master")
+			cache = NewRedisCache(c.Master, c.Options)
+		}
+	}
+	if c.Compression {
+		return NewCompressedCache(cache, c.CompressMinSize), nil
+	}
+	return cache, nil
+}
+
+// RedisCache is a Cacher on a single redis server
+type RedisCache struct {
+	client *redis.Pool
+}
+
+// NewRedisCache is the constructor for RedisCache
+func NewRedisCache(addr string, opts *RedisOptions) Cacher {
+	dopts := []redis.DialOption{
+		redis.DialPassword(opts.Password),
+		redis.DialDatabase(opts.DB),
+		redis.DialConnectTimeout(opts.DialTimeout),
+		redis.DialReadTimeout(opts.ReadTimeout),
+		redis.DialWriteTimeout(opts.WriteTimeout),
+		redis.DialUseTLS(opts.TLS),
+	}
+	if opts.TLS {
+		dopts = append(dopts, redis.DialTLSConfig(opts.tlsConfig(addr)))
+	}
+	c := &redis.Pool{
+		MaxIdle:     opts.MaxIdle,
+		MaxActive:   opts.PoolSize,
+		IdleTimeout: opts.IdleTimeout,
+		// wait for a free connection instead of failing when the pool
+		// is exhausted
+		Wait: opts.PoolSize > 0,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr, dopts...)
+		},
+	}
+	return &RedisCache{client: c}
+}
+
+// conn returns a connection from the pool along with the time left
+// before the deadline of the context, it is zero without a deadline
+func (r *RedisCache) conn(ctx context.Context) (redis.Conn, time.Duration, error) {
+	timeout, err := timeLeft(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	c, err := r.client.GetContext(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return c, timeout, nil
+}
+
+// timeLeft returns the time before the deadline of the context, it is zero
+// without a deadline. The error of a done context is returned.
+func timeLeft(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	dl, ok := ctx.Deadline()
+	if !ok {
+		return 0, nil
+	}
+	timeout := time.Until(dl)
+	if timeout <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+	return timeout, nil
+}
+
+// do runs a single command, the deadline of the context is used as
+// the read timeout of the command
+func (r *RedisCache) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	c, timeout, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return redis.DoWithTimeout(c, timeout, cmd, args...)
+}
+
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := redis.Bytes(r.do(ctx, "GET", key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// GetWithTTL runs GET and PTTL in a transaction so that both of them
+// see the same key
+func (r *RedisCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	c, timeout, err := r.conn(ctx)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer c.Close()
+	c.Send("MULTI")
+	c.Send("GET", key)
+	c.Send("PTTL", key)
+	values, err := redis.Values(redis.DoWithTimeout(c, timeout, "EXEC"))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	v, err := redis.Bytes(values[0], nil)
+	if err == redis.ErrNil {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	ms, err := redis.Int64(values[1], nil)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return v, pttlDuration(time.Duration(ms) * time.Millisecond), true, nil
+}
+
+func (r *RedisCache) Set(ctx context.Context, key string, val []byte, t time.Duration) error {
+	_, err := r.do(ctx, "SET", key, val, "EX", int64(t/time.Second))
+	return err
+}
+
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", key)
+	return err
+}
+
+func (r *RedisCache) Lock(ctx context.Context, key, token string, t time.Duration) (bool, error) {
+	_, err := redis.String(r.do(ctx, "SET", key, token, "NX", "PX", int64(t/time.Millisecond)))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *RedisCache) Unlock(ctx context.Context, key, token string) error {
+	_, err := r.do(ctx, "EVAL", unlockScript, 1, key, token)
+	return err
+}
+
+func (r *RedisCache) Extend(ctx context.Context, key, token string, t time.Duration) (bool, error) {
+	n, err := redis.Int64(r.do(ctx, "EVAL", extendScript, 1, key, token, int64(t/time.Millisecond)))
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *RedisCache) Publish(ctx context.Context, channel, msg string) error {
+	_, err := r.do(ctx, "PUBLISH", channel, msg)
+	return err
+}
+
+// Subscribe listens to the channel in the background, the connection is
+// reestablished if it is lost
+func (r *RedisCache) Subscribe(channel string, fn func(string)) (io.Closer, error) {
+	sub := &redisSubscription{done: make(chan struct{})}
+	// the first subscription is done here so that the caller gets to
+	// know about connection errors
+	psc, err := r.subscribe(channel)
+	if err != nil {
+		return nil, err
+	}
+	// set before the goroutine starts so that Close always has a
+	// connection to close
+	sub.psc = psc
+	go func() {
+		for {
+			sub.receive(psc, fn)
+			for {
+				select {
+				case <-sub.done:
+					return
+				case <-time.After(resubscribeWait):
+				}
+				psc, err = r.subscribe(channel)
+				if err == nil {
+					break
+				}
+				log.Printf("error in subscribing to %s %s", channel, err)
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// subscribe uses a connection outside of the pool, since it is held for
+// the lifetime of the subscription
+func (r *RedisCache) subscribe(channel string) (redis.PubSubConn, error) {
+	c, err := r.client.Dial()
+	if err != nil {
+		return redis.PubSubConn{}, err
+	}
+	psc := redis.PubSubConn{Conn: c}
+	if err := psc.Subscribe(channel); err != nil {
+		psc.Close()
+		return redis.PubSubConn{}, err
+	}
+	return psc, nil
+}
+
+type redisSubscription struct {
+	mu   sync.Mutex
+	psc  redis.PubSubConn
+	done chan struct{}
+	once sync.Once
+}
+
+// receive delivers the messages until the connection fails or the
+// subscription is closed
+func (s *redisSubscription) receive(psc redis.PubSubConn, fn func(string)) {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		psc.Close()
+		return
+	default:
+	}
+	s.psc = psc
+	s.mu.Unlock()
+	defer psc.Close()
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			fn(string(v.Data))
+		case error:
+			select {
+			case <-s.done:
+			default:
+				log.Printf("error in receiving from pubsub %s", v)
+			}
+			return
+		}
+	}
+}
+
+// Close stops the subscription, it could be called more than once
+func (s *redisSubscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		close(s.done)
+		err = s.psc.Close()
+	})
+	return err
+}
+
+// ClearAll removes all keys starting with the prefix and returns the
+// number of keys removed. Every command is limited to the time left before
+// the deadline of the context, which is checked between the SCAN pages.
+func (r *RedisCache) ClearAll(ctx context.Context, prefix string) (int64, error) {
+	c, _, err := r.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	do := func(cmd string, args ...interface{}) (interface{}, error) {
+		timeout, err := timeLeft(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return redis.DoWithTimeout(c, timeout, cmd, args...)
+	}
+	var total int64
+	cursor := "0"
+	for {
+		values, err := redis.Values(
+			do("SCAN", cursor, "MATCH", prefixPattern(prefix), "COUNT", scanCount),
+		)
+		if err != nil {
+			return total, err
+		}
+		cursor, err = redis.String(values[0], nil)
+		if err != nil {
+			return total, err
+		}
+		keys, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return total, err
+		}
+		if len(keys) > 0 {
+			args := redis.Args{}.AddFlat(keys)
+			n, err := redis.Int64(do("UNLINK", args...))
+			if isUnknownCommand(err) {
+				n, err = redis.Int64(do("DEL", args...))
+			}
+			if err != nil {
+				return total, err
+			}
+			total += n
+		}
+		if cursor == "0" {
+			return total, nil
+		}
+	}
+}
+
+// prefixPattern makes a SCAN pattern that matches the prefix literally
+func prefixPattern(prefix string) string {
+	var b strings.Builder
+	for _, c := range prefix {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteRune('*')
+	return b.String()
+}
+
+// isUnknownCommand is true for servers older than redis 4 that do not
+// support UNLINK
+func isUnknownCommand(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "unknown command")
+}
+
+// pttlDuration turns the negative replies of PTTL, for keys without
+// expiry, into zero
+func pttlDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// NewToken returns a random token that identifies the holder of a lock or
+// a replica of the function
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
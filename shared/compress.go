@@ -0,0 +1,229 @@
+package shared
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// DefaultCompressMinSize is the size in bytes below which values are
+// stored uncompressed
+const DefaultCompressMinSize = 1024
+
+// compressedMagic is prepended to the compressed values, it never starts
+// a JSON or XML document so values stored before compression are still
+// read as they are
+var compressedMagic = []byte("\x00GZ")
+
+// compressValue gzips the value if it is at least minSize bytes
+func compressValue(val []byte, minSize int) ([]byte, error) {
+	if len(val) < minSize {
+		return val, nil
+	}
+	var b bytes.Buffer
+	b.Write(compressedMagic)
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write(val); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	// not worth it for values that do not shrink
+	if b.Len() >= len(val) {
+		return val, nil
+	}
+	return b.Bytes(), nil
+}
+
+// decompressValue reverses compressValue, values without the magic header
+// are returned unchanged
+func decompressValue(val []byte) ([]byte, error) {
+	if !bytes.HasPrefix(val, compressedMagic) {
+		return val, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(val[len(compressedMagic):]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// CompressedCache is a Cacher that stores the values of another Cacher
+// compressed, the values smaller than minSize are kept as they are
+type CompressedCache struct {
+	cache   Cacher
+	minSize int
+}
+
+// NewCompressedCache is the constructor for CompressedCache
+func NewCompressedCache(cache Cacher, minSize int) Cacher {
+	return &CompressedCache{cache: cache, minSize: minSize}
+}
+
+func (c *CompressedCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, ok, err := c.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	v, err = decompressValue(v)
+	if err != nil {
+		return nil, false, fmt.Errorf("error in decompressing key %s %s", key, err)
+	}
+	return v, true, nil
+}
+
+func (c *CompressedCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	v, d, ok, err := c.cache.GetWithTTL(ctx, key)
+	if err != nil || !ok {
+		return nil, 0, ok, err
+	}
+	v, err = decompressValue(v)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("error in decompressing key %s %s", key, err)
+	}
+	return v, d, true, nil
+}
+
+func (c *CompressedCache) Set(ctx context.Context, key string, val []byte, t time.Duration) error {
+	v, err := compressValue(val, c.minSize)
+	if err != nil {
+		return fmt.Errorf("error in compressing key %s %s", key, err)
+	}
+	return c.cache.Set(ctx, key, v, t)
+}
+
+func (c *CompressedCache) Delete(ctx context.Context, key string) error {
+	return c.cache.Delete(ctx, key)
+}
+
+func (c *CompressedCache) ClearAll(ctx context.Context, prefix string) (int64, error) {
+	return c.cache.ClearAll(ctx, prefix)
+}
+
+// Lock acquires the lock from the wrapped cache, it is always acquired if
+// that is not a Locker
+func (c *CompressedCache) Lock(ctx context.Context, key, token string, d time.Duration) (bool, error) {
+	if l, ok := c.cache.(Locker); ok {
+		return l.Lock(ctx, key, token, d)
+	}
+	return true, nil
+}
+
+func (c *CompressedCache) Unlock(ctx context.Context, key, token string) error {
+	if l, ok := c.cache.(Locker); ok {
+		return l.Unlock(ctx, key, token)
+	}
+	return nil
+}
+
+func (c *CompressedCache) Extend(ctx context.Context, key, token string, d time.Duration) (bool, error) {
+	if l, ok := c.cache.(Locker); ok {
+		return l.Extend(ctx, key, token, d)
+	}
+	return true, nil
+}
+
+func (c *CompressedCache) Publish(ctx context.Context, channel, msg string) error {
+	if inv, ok := c.cache.(Invalidator); ok {
+		return inv.Publish(ctx, channel, msg)
+	}
+	return fmt.Errorf("%T could not publish", c.cache)
+}
+
+func (c *CompressedCache) Subscribe(channel string, fn func(string)) (io.Closer, error) {
+	if inv, ok := c.cache.(Invalidator); ok {
+		return inv.Subscribe(channel, fn)
+	}
+	return nil, fmt.Errorf("%T could not subscribe", c.cache)
+}
+
+// CompressedStorage is a Storage that keeps the values of another Storage
+// compressed, the values smaller than minSize are kept as they are
+type CompressedStorage struct {
+	storage Storage
+	minSize int
+}
+
+// NewCompressedStorage is the constructor for CompressedStorage
+func NewCompressedStorage(st Storage, minSize int) Storage {
+	return &CompressedStorage{storage: st, minSize: minSize}
+}
+
+func (c *CompressedStorage) Get(key, field string) (string, error) {
+	v, err := c.storage.Get(key, field)
+	if err != nil {
+		return v, err
+	}
+	b, err := decompressValue([]byte(v))
+	if err != nil {
+		return "", fmt.Errorf("error in decompressing %s of %s %s", field, key, err)
+	}
+	return string(b), nil
+}
+
+func (c *CompressedStorage) GetMany(key string, fields ...string) ([]string, error) {
+	values, err := c.storage.GetMany(key, fields...)
+	if err != nil {
+		return values, err
+	}
+	for i, v := range values {
+		b, err := decompressValue([]byte(v))
+		if err != nil {
+			return nil, fmt.Errorf("error in decompressing %s of %s %s", fields[i], key, err)
+		}
+		values[i] = string(b)
+	}
+	return values, nil
+}
+
+func (c *CompressedStorage) Set(key, field, val string) error {
+	b, err := compressValue([]byte(val), c.minSize)
+	if err != nil {
+		return fmt.Errorf("error in compressing %s of %s %s", field, key, err)
+	}
+	return c.storage.Set(key, field, string(b))
+}
+
+func (c *CompressedStorage) SetMany(key string, values map[string]string) error {
+	cvalues := make(map[string]string, len(values))
+	for f, v := range values {
+		b, err := compressValue([]byte(v), c.minSize)
+		if err != nil {
+			return fmt.Errorf("error in compressing %s of %s %s", f, key, err)
+		}
+		cvalues[f] = string(b)
+	}
+	return c.storage.SetMany(key, cvalues)
+}
+
+func (c *CompressedStorage) Delete(key string, fields ...string) error {
+	return c.storage.Delete(key, fields...)
+}
+
+func (c *CompressedStorage) IsExist(key, field string) bool {
+	return c.storage.IsExist(key, field)
+}
+
+func (c *CompressedStorage) ClearAll(prefix string) (int64, error) {
+	return c.storage.ClearAll(prefix)
+}
+
+// AddSorted adds the members uncompressed, they are small and have to be
+// unique within the sorted set
+func (c *CompressedStorage) AddSorted(key string, members ...SortedMember) error {
+	return c.storage.AddSorted(key, members...)
+}
+
+func (c *CompressedStorage) RangeSorted(key string, min, max float64) ([]string, error) {
+	return c.storage.RangeSorted(key, min, max)
+}
+
+func (c *CompressedStorage) Close() error {
+	return c.storage.Close()
+}
@@ -0,0 +1,68 @@
+package shared
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressValue(t *testing.T) {
+	long := []byte(strings.Repeat(`{"name":"value"}`, 100))
+	tests := []struct {
+		name       string
+		value      []byte
+		minSize    int
+		compressed bool
+	}{
+		{"below minimum", []byte(`{"a":1}`), 1024, false},
+		{"compressible", long, 1024, true},
+		{"not shrinking", []byte("abc"), 0, false},
+		{"empty", []byte{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := compressValue(tt.value, tt.minSize)
+			if err != nil {
+				t.Fatalf("compressValue() error %s", err)
+			}
+			if got := bytes.HasPrefix(c, compressedMagic); got != tt.compressed {
+				t.Errorf("compressed %t, want %t", got, tt.compressed)
+			}
+			if tt.compressed && len(c) >= len(tt.value) {
+				t.Errorf("compressed size %d is not less than %d", len(c), len(tt.value))
+			}
+			d, err := decompressValue(c)
+			if err != nil {
+				t.Fatalf("decompressValue() error %s", err)
+			}
+			if !bytes.Equal(d, tt.value) {
+				t.Errorf("decompressValue() = %q, want %q", d, tt.value)
+			}
+		})
+	}
+}
+
+func TestDecompressValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"raw json", []byte(`{"a":1}`), []byte(`{"a":1}`), false},
+		{"raw xml", []byte(`<a/>`), []byte(`<a/>`), false},
+		{"empty", []byte{}, []byte{}, false},
+		{"corrupt", append([]byte("\x00GZ"), "junk"...), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decompressValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decompressValue() error %v, want error %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, tt.want) {
+				t.Errorf("decompressValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package shared
+
+import (
+	"crypto/tls"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	ModeSentinel    = "sentinel"
+	ModeCluster     = "cluster"
+	ModeReplication = "replication"
+)
+
+const (
+	defaultDialTimeout  = 5 * time.Second
+	defaultReadTimeout  = 3 * time.Second
+	defaultWriteTimeout = 3 * time.Second
+	defaultIdleTimeout  = 180 * time.Second
+	defaultMaxIdle      = 4
+)
+
+// RedisConfig is the redis deployment that the functions connect to
+type RedisConfig struct {
+	// Mode is either sentinel, cluster or replication
+	Mode string
+	// Master and Slave are the host:port addresses for replication, Slave
+	// is optional
+	Master string
+	Slave  string
+	// SentinelMaster is the name of the master known to the SentinelAddrs
+	SentinelMaster string
+	SentinelAddrs  []string
+	ClusterAddrs   []string
+	// keys are read from master for this duration after they are written
+	ReadYourWrites time.Duration
+	// values of at least CompressMinSize bytes are stored compressed
+	Compression     bool
+	CompressMinSize int
+	Options         *RedisOptions
+}
+
+// RedisConfigFromEnv reads the redis deployment from the environment,
+//   - REDIS_MODE: sentinel, cluster or replication(default)
+//   - REDIS_MASTER_SERVICE_HOST, REDIS_MASTER_SERVICE_PORT,
+//     REDIS_SLAVE_SERVICE_HOST and REDIS_SLAVE_SERVICE_PORT for replication
+//   - REDIS_SENTINEL_MASTER and REDIS_SENTINEL_ADDRS for sentinel
+//   - REDIS_CLUSTER_ADDRS for cluster
+//   - REDIS_READ_YOUR_WRITES, REDIS_COMPRESSION and REDIS_COMPRESS_MIN_SIZE
+//
+// The connection options are read with RedisOptionsFromEnv.
+func RedisConfigFromEnv() *RedisConfig {
+	c := &RedisConfig{
+		Mode:            os.Getenv("REDIS_MODE"),
+		Master:          hostPortEnv("REDIS_MASTER_SERVICE_HOST", "REDIS_MASTER_SERVICE_PORT"),
+		Slave:           hostPortEnv("REDIS_SLAVE_SERVICE_HOST", "REDIS_SLAVE_SERVICE_PORT"),
+		SentinelMaster:  os.Getenv("REDIS_SENTINEL_MASTER"),
+		SentinelAddrs:   AddrsEnv("REDIS_SENTINEL_ADDRS"),
+		ClusterAddrs:    AddrsEnv("REDIS_CLUSTER_ADDRS"),
+		ReadYourWrites:  DurationEnv("REDIS_READ_YOUR_WRITES", 0),
+		Compression:     BoolEnv("REDIS_COMPRESSION", true),
+		CompressMinSize: IntEnv("REDIS_COMPRESS_MIN_SIZE", DefaultCompressMinSize),
+		Options:         RedisOptionsFromEnv(),
+	}
+	switch c.Mode {
+	case ModeSentinel, ModeCluster, ModeReplication:
+	case "":
+		c.Mode = ModeReplication
+	default:
+		log.Printf("unknown REDIS_MODE %s, using %s", c.Mode, ModeReplication)
+		c.Mode = ModeReplication
+	}
+	return c
+}
+
+// RedisOptions are the connection settings shared by all the redis
+// backends
+type RedisOptions struct {
+	Password string
+	DB       int
+	TLS      bool
+	// name that is verified with the certificate of the server, the host
+	// of the address is used if it is empty
+	TLSServerName string
+	DialTimeout   time.Duration
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	// maximum number of connections, zero leaves it to the client
+	PoolSize int
+	// maximum number of idle connections, only used by RedisCache
+	MaxIdle     int
+	IdleTimeout time.Duration
+}
+
+// RedisOptionsFromEnv reads the options from the REDIS_PASSWORD, REDIS_DB,
+// REDIS_TLS, REDIS_TLS_SERVER_NAME, REDIS_DIAL_TIMEOUT, REDIS_READ_TIMEOUT, REDIS_WRITE_TIMEOUT,
+// REDIS_POOL_SIZE, REDIS_MAX_IDLE and REDIS_IDLE_TIMEOUT environment
+// variables
+func RedisOptionsFromEnv() *RedisOptions {
+	return &RedisOptions{
+		Password:      os.Getenv("REDIS_PASSWORD"),
+		DB:            IntEnv("REDIS_DB", 0),
+		TLS:           BoolEnv("REDIS_TLS", false),
+		TLSServerName: os.Getenv("REDIS_TLS_SERVER_NAME"),
+		DialTimeout:   DurationEnv("REDIS_DIAL_TIMEOUT", defaultDialTimeout),
+		ReadTimeout:   DurationEnv("REDIS_READ_TIMEOUT", defaultReadTimeout),
+		WriteTimeout:  DurationEnv("REDIS_WRITE_TIMEOUT", defaultWriteTimeout),
+		PoolSize:      IntEnv("REDIS_POOL_SIZE", 0),
+		MaxIdle:       IntEnv("REDIS_MAX_IDLE", defaultMaxIdle),
+		IdleTimeout:   DurationEnv("REDIS_IDLE_TIMEOUT", defaultIdleTimeout),
+	}
+}
+
+// tlsConfig returns the TLS configuration for the server at addr, it is
+// nil when TLS is not enabled
+func (o *RedisOptions) tlsConfig(addr string) *tls.Config {
+	if !o.TLS {
+		return nil
+	}
+	if len(o.TLSServerName) > 0 {
+		return &tls.Config{ServerName: o.TLSServerName}
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return &tls.Config{ServerName: host}
+}
+
+func (o *RedisOptions) client(addr string) *redis.Options {
+	return &redis.Options{
+		Addr:         addr,
+		Password:     o.Password,
+		DB:           o.DB,
+		DialTimeout:  o.DialTimeout,
+		ReadTimeout:  o.ReadTimeout,
+		WriteTimeout: o.WriteTimeout,
+		PoolSize:     o.PoolSize,
+		IdleTimeout:  o.IdleTimeout,
+		TLSConfig:    o.tlsConfig(addr),
+	}
+}
+
+func (o *RedisOptions) failover(masterName string, sentinels []string) *redis.FailoverOptions {
+	return &redis.FailoverOptions{
+		MasterName:    masterName,
+		SentinelAddrs: sentinels,
+		Password:      o.Password,
+		DB:            o.DB,
+		DialTimeout:   o.DialTimeout,
+		ReadTimeout:   o.ReadTimeout,
+		WriteTimeout:  o.WriteTimeout,
+		PoolSize:      o.PoolSize,
+		IdleTimeout:   o.IdleTimeout,
+		// the address of master is only known from the sentinels
+		TLSConfig: o.tlsConfig(""),
+	}
+}
+
+// cluster does not use the DB, since a redis cluster has only one
+func (o *RedisOptions) cluster(addrs []string) *redis.ClusterOptions {
+	return &redis.ClusterOptions{
+		Addrs:        addrs,
+		Password:     o.Password,
+		DialTimeout:  o.DialTimeout,
+		ReadTimeout:  o.ReadTimeout,
+		WriteTimeout: o.WriteTimeout,
+		PoolSize:     o.PoolSize,
+		IdleTimeout:  o.IdleTimeout,
+		// every node is verified against its own address, including
+		// the ones found through the seeds
+		TLSConfig: o.tlsConfig(""),
+	}
+}
+
+// AddrsEnv returns the comma separated addresses of the environment
+// variable
+func AddrsEnv(name string) []string {
+	var addrs []string
+	for _, a := range strings.Split(os.Getenv(name), ",") {
+		if a = strings.TrimSpace(a); len(a) > 0 {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// DurationEnv parses the duration in the environment variable, the default
+// is returned if it is not set or invalid
+func DurationEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if len(v) == 0 {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s %s, using %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// IntEnv parses the integer in the environment variable, the default is
+// returned if it is not set or invalid
+func IntEnv(name string, def int) int {
+	v := os.Getenv(name)
+	if len(v) == 0 {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("invalid %s %s, using %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// BoolEnv parses the boolean in the environment variable, the default is
+// returned if it is not set or invalid
+func BoolEnv(name string, def bool) bool {
+	v := os.Getenv(name)
+	if len(v) == 0 {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid %s %s, using %t", name, v, def)
+		return def
+	}
+	return b
+}
+
+// hostPortEnv joins the host and port of the environment variables, it is
+// empty if either of them is not set
+func hostPortEnv(host, port string) string {
+	h := os.Getenv(host)
+	p := os.Getenv(port)
+	if len(h) == 0 || len(p) == 0 {
+		return ""
+	}
+	return net.JoinHostPort(h, p)
+}
@@ -0,0 +1,225 @@
+package shared
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setEnv sets the environment variables, the ones not given are unset, and
+// returns a function that restores them
+func setEnv(vars map[string]string, names ...string) func() {
+	old := make(map[string]*string)
+	for _, n := range names {
+		if v, ok := os.LookupEnv(n); ok {
+			old[n] = &v
+		} else {
+			old[n] = nil
+		}
+		if v, ok := vars[n]; ok {
+			os.Setenv(n, v)
+		} else {
+			os.Unsetenv(n)
+		}
+	}
+	return func() {
+		for n, v := range old {
+			if v == nil {
+				os.Unsetenv(n)
+			} else {
+				os.Setenv(n, *v)
+			}
+		}
+	}
+}
+
+func TestIntEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{"unset", "", 7},
+		{"number", "42", 42},
+		{"zero", "0", 0},
+		{"negative", "-1", 7},
+		{"invalid", "abc", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(map[string]string{"TEST_INT": tt.value}, "TEST_INT")()
+			if got := IntEnv("TEST_INT", 7); got != tt.want {
+				t.Errorf("IntEnv() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"unset", "", time.Minute},
+		{"duration", "90s", 90 * time.Second},
+		{"zero", "0s", time.Minute},
+		{"negative", "-5s", time.Minute},
+		{"without unit", "10", time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(map[string]string{"TEST_DURATION": tt.value}, "TEST_DURATION")()
+			if got := DurationEnv("TEST_DURATION", time.Minute); got != tt.want {
+				t.Errorf("DurationEnv() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoolEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		def   bool
+		want  bool
+	}{
+		{"unset", "", true, true},
+		{"false", "false", true, false},
+		{"true", "1", false, true},
+		{"invalid", "yes", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(map[string]string{"TEST_BOOL": tt.value}, "TEST_BOOL")()
+			if got := BoolEnv("TEST_BOOL", tt.def); got != tt.want {
+				t.Errorf("BoolEnv() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisConfigFromEnv(t *testing.T) {
+	names := []string{
+		"REDIS_MODE",
+		"REDIS_MASTER_SERVICE_HOST",
+		"REDIS_MASTER_SERVICE_PORT",
+		"REDIS_SLAVE_SERVICE_HOST",
+		"REDIS_SLAVE_SERVICE_PORT",
+		"REDIS_SENTINEL_MASTER",
+		"REDIS_SENTINEL_ADDRS",
+		"REDIS_CLUSTER_ADDRS",
+		"REDIS_READ_YOUR_WRITES",
+		"REDIS_COMPRESSION",
+		"REDIS_COMPRESS_MIN_SIZE",
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *RedisConfig
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{},
+			want: &RedisConfig{
+				Mode:            ModeReplication,
+				Compression:     true,
+				CompressMinSize: DefaultCompressMinSize,
+			},
+		},
+		{
+			name: "replication",
+			env: map[string]string{
+				"REDIS_MASTER_SERVICE_HOST": "master",
+				"REDIS_MASTER_SERVICE_PORT": "6379",
+				"REDIS_SLAVE_SERVICE_HOST":  "slave",
+				"REDIS_COMPRESSION":         "false",
+				"REDIS_COMPRESS_MIN_SIZE":   "10",
+			},
+			want: &RedisConfig{
+				Mode:            ModeReplication,
+				Master:          "master:6379",
+				CompressMinSize: 10,
+			},
+		},
+		{
+			name: "sentinel",
+			env: map[string]string{
+				"REDIS_MODE":            "sentinel",
+				"REDIS_SENTINEL_MASTER": "mymaster",
+				"REDIS_SENTINEL_ADDRS":  "s1:26379, s2:26379,",
+			},
+			want: &RedisConfig{
+				Mode:            ModeSentinel,
+				SentinelMaster:  "mymaster",
+				SentinelAddrs:   []string{"s1:26379", "s2:26379"},
+				Compression:     true,
+				CompressMinSize: DefaultCompressMinSize,
+			},
+		},
+		{
+			name: "cluster",
+			env: map[string]string{
+				"REDIS_MODE":          "cluster",
+				"REDIS_CLUSTER_ADDRS": "n1:6379,n2:6379",
+			},
+			want: &RedisConfig{
+				Mode:            ModeCluster,
+				ClusterAddrs:    []string{"n1:6379", "n2:6379"},
+				Compression:     true,
+				CompressMinSize: DefaultCompressMinSize,
+			},
+		},
+		{
+			name: "unknown mode",
+			env:  map[string]string{"REDIS_MODE": "standalone"},
+			want: &RedisConfig{
+				Mode:            ModeReplication,
+				Compression:     true,
+				CompressMinSize: DefaultCompressMinSize,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(tt.env, names...)()
+			got := RedisConfigFromEnv()
+			// the connection options are not part of this test
+			got.Options = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedisConfigFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisOptionsClusterTLS(t *testing.T) {
+	addrs := []string{"n1.redis:6379", "n2.redis:6379", "n3.redis:6379"}
+	tests := []struct {
+		name       string
+		opts       *RedisOptions
+		wantTLS    bool
+		serverName string
+	}{
+		{"without tls", &RedisOptions{}, false, ""},
+		{"per node", &RedisOptions{TLS: true}, true, ""},
+		{
+			"server name",
+			&RedisOptions{TLS: true, TLSServerName: "redis.example.org"},
+			true,
+			"redis.example.org",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.opts.cluster(addrs).TLSConfig
+			if (c != nil) != tt.wantTLS {
+				t.Fatalf("tls config %v, want tls %t", c, tt.wantTLS)
+			}
+			if c != nil && c.ServerName != tt.serverName {
+				t.Errorf("server name %q, want %q", c.ServerName, tt.serverName)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/dictybase-playground/kubeless-gofn/shared
+
+go 1.13
+
+require (
+	github.com/dictyBase/apihelpers v0.0.0-20180801151846-aa9d10182786
+	github.com/go-redis/redis v6.13.2+incompatible
+	github.com/gomodule/redigo v2.0.0+incompatible
+	github.com/spacemonkeygo/errors v0.0.0-20171212215202-9064522e9fd1
+)
@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/spacemonkeygo/errors"
+	"github.com/spacemonkeygo/errors/errhttp"
+)
+
+var (
+	// TitleErrKey is the key for the title of the error
+	TitleErrKey = errors.GenSym()
+	// PointerErrKey is the key for the JSON pointer to the invalid part of
+	// the request body
+	PointerErrKey = errors.GenSym()
+	// ParamErrKey is the key for the invalid query parameter
+	ParamErrKey = errors.GenSym()
+	// MetaErrKey is the key for a map that is added to the meta of the
+	// error
+	MetaErrKey = errors.GenSym()
+)
+
+// JSONAPIError generate JSONAPI formatted http error from an error object
+func JSONAPIError(err error) (string, int, error) {
+	status := errhttp.GetStatusCode(err, http.StatusInternalServerError)
+	title, _ := errors.GetData(err, TitleErrKey).(string)
+	meta := map[string]interface{}{
+		"creator": "kubeless gofn error",
+	}
+	if m, ok := errors.GetData(err, MetaErrKey).(map[string]interface{}); ok {
+		for k, v := range m {
+			meta[k] = v
+		}
+	}
+	jsnErr := apherror.Error{
+		Status: strconv.Itoa(status),
+		Title:  title,
+		Detail: errhttp.GetErrorBody(err),
+		Meta:   meta,
+	}
+	errSource := new(apherror.ErrorSource)
+	pointer, ok := errors.GetData(err, PointerErrKey).(string)
+	if ok {
+		errSource.Pointer = pointer
+	}
+	param, ok := errors.GetData(err, ParamErrKey).(string)
+	if ok {
+		errSource.Parameter = param
+	}
+	jsnErr.Source = errSource
+	ct, encErr := json.Marshal(apherror.HTTPError{Errors: []apherror.Error{jsnErr}})
+	if encErr != nil {
+		return "", http.StatusInternalServerError, encErr
+	}
+	return string(ct), status, nil
+}
@@ -0,0 +1,119 @@
+package shared
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/dictyBase/apihelpers/apherror"
+	"github.com/spacemonkeygo/errors"
+	"github.com/spacemonkeygo/errors/errhttp"
+)
+
+var testErrClass = errors.NewClass(
+	"test error",
+	errhttp.SetStatusCode(http.StatusUnprocessableEntity),
+	errors.SetData(TitleErrKey, "Invalid input"),
+)
+
+func TestJSONAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantTitle  string
+		wantSource apherror.ErrorSource
+		wantMeta   map[string]interface{}
+	}{
+		{
+			name:       "plain error",
+			err:        apherror.ErrNotFound.New("no such thing"),
+			wantStatus: http.StatusNotFound,
+			wantMeta:   map[string]interface{}{"creator": "kubeless gofn error"},
+		},
+		{
+			name:       "title",
+			err:        testErrClass.New("bad"),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantTitle:  "Invalid input",
+			wantMeta:   map[string]interface{}{"creator": "kubeless gofn error"},
+		},
+		{
+			name: "pointer and param",
+			err: testErrClass.NewWith(
+				"bad",
+				errors.SetData(PointerErrKey, "/data/attributes/name"),
+				errors.SetData(ParamErrKey, "start"),
+			),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantTitle:  "Invalid input",
+			wantSource: apherror.ErrorSource{
+				Pointer:   "/data/attributes/name",
+				Parameter: "start",
+			},
+			wantMeta: map[string]interface{}{"creator": "kubeless gofn error"},
+		},
+		{
+			name: "meta merged",
+			err: testErrClass.NewWith(
+				"bad",
+				errors.SetData(MetaErrKey, map[string]interface{}{
+					"lines":   float64(10),
+					"creator": "validator",
+				}),
+			),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantTitle:  "Invalid input",
+			wantMeta: map[string]interface{}{
+				"creator": "validator",
+				"lines":   float64(10),
+			},
+		},
+		{
+			name:       "without status",
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+			wantMeta:   map[string]interface{}{"creator": "kubeless gofn error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct, status, err := JSONAPIError(tt.err)
+			if err != nil {
+				t.Fatalf("JSONAPIError() error %s", err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("status %d, want %d", status, tt.wantStatus)
+			}
+			var doc struct {
+				Errors []struct {
+					Status string                 `json:"status"`
+					Title  string                 `json:"title"`
+					Source apherror.ErrorSource   `json:"source"`
+					Meta   map[string]interface{} `json:"meta"`
+				} `json:"errors"`
+			}
+			if err := json.Unmarshal([]byte(ct), &doc); err != nil {
+				t.Fatalf("error in decoding %s %s", ct, err)
+			}
+			if len(doc.Errors) != 1 {
+				t.Fatalf("got %d errors, want 1", len(doc.Errors))
+			}
+			e := doc.Errors[0]
+			if e.Status != strconv.Itoa(tt.wantStatus) {
+				t.Errorf("status member %s, want %d", e.Status, tt.wantStatus)
+			}
+			if e.Title != tt.wantTitle {
+				t.Errorf("title %q, want %q", e.Title, tt.wantTitle)
+			}
+			if e.Source != tt.wantSource {
+				t.Errorf("source %+v, want %+v", e.Source, tt.wantSource)
+			}
+			if !reflect.DeepEqual(e.Meta, tt.wantMeta) {
+				t.Errorf("meta %v, want %v", e.Meta, tt.wantMeta)
+			}
+		})
+	}
+}
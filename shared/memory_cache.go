@@ -0,0 +1,132 @@
+package shared
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+func (e *memoryEntry) isExpired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+// MemoryCache is an in-process Cacher that keeps a bounded number of
+// entries, the least recently used one is evicted to make room for a
+// new entry
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+// NewMemoryCache is the constructor for MemoryCache that holds at most
+// capacity entries
+func NewMemoryCache(capacity int) Cacher {
+	return &MemoryCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// lookup returns the live entry for the key, an expired entry is
+// removed. It has to be called with the lock held.
+func (m *MemoryCache) lookup(key string) (*memoryEntry, bool) {
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if entry.isExpired(m.now()) {
+		m.removeElement(el)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (m *MemoryCache) removeElement(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, _, ok, err := m.GetWithTTL(ctx, key)
+	return v, ok, err
+}
+
+func (m *MemoryCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.lookup(key)
+	if !ok {
+		return nil, 0, false, nil
+	}
+	m.order.MoveToFront(m.entries[key])
+	var ttl time.Duration
+	if !entry.expireAt.IsZero() {
+		ttl = entry.expireAt.Sub(m.now())
+	}
+	return entry.value, ttl, true, nil
+}
+
+// Set stores the value of the key, a zero duration keeps the value until
+// it is evicted
+func (m *MemoryCache) Set(ctx context.Context, key string, val []byte, t time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expireAt time.Time
+	if t > 0 {
+		expireAt = m.now().Add(t)
+	}
+	if el, ok := m.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = val
+		entry.expireAt = expireAt
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{
+		key:      key,
+		value:    val,
+		expireAt: expireAt,
+	})
+	for m.capacity > 0 && m.order.Len() > m.capacity {
+		m.removeElement(m.order.Back())
+	}
+	return nil
+}
+
+func (m *MemoryCache) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.removeElement(el)
+	}
+	return nil
+}
+
+// ClearAll removes all keys starting with the prefix and returns the
+// number of keys removed
+func (m *MemoryCache) ClearAll(ctx context.Context, prefix string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total int64
+	for key, el := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			m.removeElement(el)
+			total++
+		}
+	}
+	return total, nil
+}
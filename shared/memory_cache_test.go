@@ -0,0 +1,101 @@
+package shared
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		capacity int
+		// keys are set in order, the ones in touch are read after all of
+		// them are set except the last
+		keys  []string
+		touch []string
+		want  map[string]bool
+	}{
+		{
+			name:     "within capacity",
+			capacity: 3,
+			keys:     []string{"a", "b", "c"},
+			want:     map[string]bool{"a": true, "b": true, "c": true},
+		},
+		{
+			name:     "least recently set",
+			capacity: 2,
+			keys:     []string{"a", "b", "c"},
+			want:     map[string]bool{"a": false, "b": true, "c": true},
+		},
+		{
+			name:     "least recently used",
+			capacity: 2,
+			keys:     []string{"a", "b", "c"},
+			touch:    []string{"a"},
+			want:     map[string]bool{"a": true, "b": false, "c": true},
+		},
+		{
+			name:     "unbounded",
+			capacity: 0,
+			keys:     []string{"a", "b", "c"},
+			want:     map[string]bool{"a": true, "b": true, "c": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemoryCache(tt.capacity)
+			for i, k := range tt.keys {
+				if i == len(tt.keys)-1 {
+					for _, tk := range tt.touch {
+						m.Get(ctx, tk)
+					}
+				}
+				m.Set(ctx, k, []byte(k), 0)
+			}
+			for k, want := range tt.want {
+				if _, ok, _ := m.Get(ctx, k); ok != want {
+					t.Errorf("key %s found %t, want %t", k, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		elapsed time.Duration
+		found   bool
+		wantTTL time.Duration
+	}{
+		{"live", time.Minute, 20 * time.Second, true, 40 * time.Second},
+		{"expired", time.Minute, 61 * time.Second, false, 0},
+		{"without expiry", 0, 24 * time.Hour, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			m := NewMemoryCache(10).(*MemoryCache)
+			m.now = func() time.Time { return now }
+			m.Set(ctx, "key", []byte("value"), tt.ttl)
+			now = now.Add(tt.elapsed)
+			_, ttl, ok, err := m.GetWithTTL(ctx, "key")
+			if err != nil {
+				t.Fatalf("GetWithTTL() error %s", err)
+			}
+			if ok != tt.found {
+				t.Fatalf("found %t, want %t", ok, tt.found)
+			}
+			if ttl != tt.wantTTL {
+				t.Errorf("ttl %s, want %s", ttl, tt.wantTTL)
+			}
+			if !tt.found && len(m.entries) != 0 {
+				t.Errorf("expired entry is not removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package shared
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// consecutive failures after which the replica is not read from
+	replicaFailureLimit = 3
+	// duration for which reads go to master once the replica has failed
+	replicaCooldown = 30 * time.Second
+	// number of recently written keys that are read from master
+	recentWritesSize = 10000
+)
+
+// RedisReplicationCache writes to master and reads from the replica, the
+// reads fall back to master if the replica fails
+type RedisReplicationCache struct {
+	master  *redis.Client
+	slave   *redis.Client
+	breaker *replicaBreaker
+	// keys written within the window are read from master, nil if
+	// read-your-writes is disabled
+	written Cacher
+	window  time.Duration
+}
+
+// NewRedisReplicationCache is the constructor for RedisReplicationCache,
+// keys are read from master for the window after they are written, a zero
+// window always reads them from the replica
+func NewRedisReplicationCache(master, slave string, window time.Duration, opts *RedisOptions) Cacher {
+	r := &RedisReplicationCache{
+		master:  redis.NewClient(opts.client(master)),
+		slave:   redis.NewClient(opts.client(slave)),
+		breaker: &replicaBreaker{},
+		window:  window,
+	}
+	if window > 0 {
+		r.written = NewMemoryCache(recentWritesSize)
+	}
+	return r
+}
+
+// replicaBreaker keeps track of the failures of the replica, it is open
+// for a cooldown after too many failures in a row. A single failure
+// opens it again until the replica has served a read.
+type replicaBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (b *replicaBreaker) healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().After(b.openUntil)
+}
+
+func (b *replicaBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+func (b *replicaBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures < replicaFailureLimit && b.openUntil.IsZero() {
+		return
+	}
+	b.failures = 0
+	b.openUntil = time.Now().Add(replicaCooldown)
+	log.Printf("replica is unhealthy, reading from master for %s", replicaCooldown)
+}
+
+// read runs the command on the replica, it runs on master instead if the
+// replica fails, is unhealthy or the key was written recently
+func (r *RedisReplicationCache) read(ctx context.Context, key string, cmd func(*redis.Client) error) error {
+	if !r.breaker.healthy() || r.isRecent(ctx, key) {
+		return cmd(r.master.WithContext(ctx))
+	}
+	err := cmd(r.slave.WithContext(ctx))
+	if err == nil || err == redis.Nil {
+		r.breaker.success()
+		return err
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	r.breaker.failure()
+	log.Printf("error in reading key %s from replica %s, reading from master", key, err)
+	return cmd(r.master.WithContext(ctx))
+}
+
+func (r *RedisReplicationCache) isRecent(ctx context.Context, key string) bool {
+	if r.written == nil {
+		return false
+	}
+	_, ok, _ := r.written.Get(ctx, key)
+	return ok
+}
+
+func (r *RedisReplicationCache) markWritten(ctx context.Context, key string) {
+	if r.written == nil {
+		return
+	}
+	r.written.Set(ctx, key, nil, r.window)
+}
+
+func (r *RedisReplicationCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var v []byte
+	err := r.read(ctx, key, func(c *redis.Client) error {
+		var err error
+		v, err = c.Get(key).Bytes()
+		return err
+	})
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+func (r *RedisReplicationCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	var v []byte
+	var d time.Duration
+	err := r.read(ctx, key, func(c *redis.Client) error {
+		var err error
+		v, d, err = getWithTTL(c, key)
+		return err
+	})
+	if err == redis.Nil {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return v, d, true, nil
+}
+
+func (r *RedisReplicationCache) Set(ctx context.Context, key string, val []byte, t time.Duration) error {
+	if err := r.master.WithContext(ctx).Set(key, val, t).Err(); err != nil {
+		return err
+	}
+	r.markWritten(ctx, key)
+	return nil
+}
+
+func (r *RedisReplicationCache) Delete(ctx context.Context, key string) error {
+	if err := r.master.WithContext(ctx).Del(key).Err(); err != nil {
+		return err
+	}
+	r.markWritten(ctx, key)
+	return nil
+}
+
+func (r *RedisReplicationCache) Lock(ctx context.Context, key, token string, t time.Duration) (bool, error) {
+	return r.master.WithContext(ctx).SetNX(key, token, t).Result()
+}
+
+func (r *RedisReplicationCache) Unlock(ctx context.Context, key, token string) error {
+	return r.master.WithContext(ctx).Eval(unlockScript, []string{key}, token).Err()
+}
+
+func (r *RedisReplicationCache) Extend(ctx context.Context, key, token string, t time.Duration) (bool, error) {
+	v, err := r.master.WithContext(ctx).Eval(
+		extendScript, []string{key}, token, int64(t/time.Millisecond),
+	).Result()
+	if err != nil {
+		return false, err
+	}
+	n, ok := v.(int64)
+	return ok && n == 1, nil
+}
+
+func (r *RedisReplicationCache) Publish(ctx context.Context, channel, msg string) error {
+	return r.master.WithContext(ctx).Publish(channel, msg).Err()
+}
+
+// Subscribe listens to the channel of master in the background
+func (r *RedisReplicationCache) Subscribe(channel string, fn func(string)) (io.Closer, error) {
+	return receiveChannel(r.master.Subscribe(channel), fn)
+}
+
+// ClearAll removes all keys starting with the prefix and returns the
+// number of keys removed
+func (r *RedisReplicationCache) ClearAll(ctx context.Context, prefix string) (int64, error) {
+	return clearPrefix(r.master.WithContext(ctx), prefix)
+}
+
+// getWithTTL runs GET and PTTL in a transaction so that both of them see
+// the same key, redis.Nil is returned for a missing key
+func getWithTTL(c redis.Cmdable, key string) ([]byte, time.Duration, error) {
+	pipe := c.TxPipeline()
+	get := pipe.Get(key)
+	pttl := pipe.PTTL(key)
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, 0, err
+	}
+	v, err := get.Bytes()
+	return v, pttlDuration(pttl.Val()), err
+}
+
+// receiveChannel calls fn with the messages of the subscription in the
+// background
+func receiveChannel(ps *redis.PubSub, fn func(string)) (io.Closer, error) {
+	if _, err := ps.Receive(); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	go func() {
+		for msg := range ps.Channel() {
+			fn(msg.Payload)
+		}
+	}()
+	return ps, nil
+}
+
+// clearPrefix removes all keys of the server starting with the prefix. The
+// keys are unlinked one at a time in a pipeline, since a cluster node
+// rejects the commands with keys from more than one slot.
+func clearPrefix(c redis.Cmdable, prefix string) (int64, error) {
+	var total int64
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(cursor, prefixPattern(prefix), scanCount).Result()
+		if err != nil {
+			return total, err
+		}
+		if len(keys) > 0 {
+			n, err := removeKeys(c, keys, true)
+			if isUnknownCommand(err) {
+				n, err = removeKeys(c, keys, false)
+			}
+			if err != nil {
+				return total, err
+			}
+			total += n
+		}
+		if next == 0 {
+			return total, nil
+		}
+		cursor = next
+	}
+}
+
+// removeKeys removes the keys in a pipeline with UNLINK, or with DEL if
+// unlink is false
+func removeKeys(c redis.Cmdable, keys []string, unlink bool) (int64, error) {
+	pipe := c.Pipeline()
+	defer pipe.Close()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, k := range keys {
+		if unlink {
+			cmds[i] = pipe.Unlink(k)
+		} else {
+			cmds[i] = pipe.Del(k)
+		}
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return total, nil
+}
@@ -0,0 +1,210 @@
+package shared
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/go-redis/redis"
+)
+
+// Storage interface is for managing hash based key value data
+type Storage interface {
+	// Get fetches the value of a hash field, the error of a missing field
+	// is reported by IsNotFound
+	Get(string, string) (string, error)
+	// GetMany fetches the values of multiple hash fields, an error is
+	// returned if any of them is missing
+	GetMany(string, ...string) ([]string, error)
+	Set(string, string, string) error
+	// SetMany sets the values of multiple hash fields at once
+	SetMany(string, map[string]string) error
+	Delete(string, ...string) error
+	IsExist(string, string) bool
+	// ClearAll removes all keys starting with the prefix and returns the
+	// number of keys removed
+	ClearAll(string) (int64, error)
+	// AddSorted adds the members to the sorted set of the key
+	AddSorted(string, ...SortedMember) error
+	// RangeSorted returns the members of the sorted set of the key with
+	// scores between min and max, both inclusive, ordered by score
+	RangeSorted(string, float64, float64) ([]string, error)
+	Close() error
+}
+
+// ErrNotFound is the error returned by Get for a missing hash field
+var ErrNotFound = redis.Nil
+
+// IsNotFound is true for the error returned by Get for a missing hash
+// field
+func IsNotFound(err error) bool {
+	return err == ErrNotFound
+}
+
+// SortedMember is a member of a sorted set along with its score
+type SortedMember struct {
+	Score  float64
+	Member string
+}
+
+// RedisStorage is a Storage that writes to master and reads from the
+// replica, both are the same client for sentinel and cluster
+type RedisStorage struct {
+	master redis.UniversalClient
+	slave  redis.UniversalClient
+}
+
+// NewRedisStorage is the constructor for redis for storing hash based key
+// value, master is also read from if slave is empty
+func NewRedisStorage(master, slave string, opts *RedisOptions) Storage {
+	m := redis.NewClient(opts.client(master))
+	if len(slave) == 0 {
+		return &RedisStorage{master: m, slave: m}
+	}
+	return &RedisStorage{master: m, slave: redis.NewClient(opts.client(slave))}
+}
+
+// NewRedisSentinelStorage is the constructor for redis that connects to
+// the master known to the sentinels by the given name
+func NewRedisSentinelStorage(masterName string, sentinels []string, opts *RedisOptions) Storage {
+	client := redis.NewFailoverClient(opts.failover(masterName, sentinels))
+	return &RedisStorage{master: client, slave: client}
+}
+
+// NewRedisClusterStorage is the constructor for redis that connects to a
+// redis cluster through the given nodes
+func NewRedisClusterStorage(addrs []string, opts *RedisOptions) Storage {
+	client := redis.NewClusterClient(opts.cluster(addrs))
+	return &RedisStorage{master: client, slave: client}
+}
+
+// NewStorage returns the Storage for the redis deployment of the config,
+// the values are compressed if it is enabled. ErrNoBackend is returned if
+// redis is not configured.
+func NewStorage(c *RedisConfig) (Storage, error) {
+	var st Storage
+	switch c.Mode {
+	case ModeSentinel:
+		if len(c.SentinelMaster) == 0 || len(c.SentinelAddrs) == 0 {
+			return st, fmt.Errorf("REDIS_SENTINEL_MASTER and REDIS_SENTINEL_ADDRS are needed for sentinel")
+		}
+		st = NewRedisSentinelStorage(c.SentinelMaster, c.SentinelAddrs, c.Options)
+	case ModeCluster:
+		if len(c.ClusterAddrs) == 0 {
+			return st, fmt.Errorf("REDIS_CLUSTER_ADDRS is needed for cluster")
+		}
+		st = NewRedisClusterStorage(c.ClusterAddrs, c.Options)
+	default:
+		if len(c.Master) == 0 {
+			return st, ErrNoBackend
+		}
+		st = NewRedisStorage(c.Master, c.Slave, c.Options)
+	}
+	if c.Compression {
+		return NewCompressedStorage(st, c.CompressMinSize), nil
+	}
+	return st, nil
+}
+
+// Close closes the redis connection
+func (r *RedisStorage) Close() error {
+	if err := r.master.Close(); err != nil {
+		return err
+	}
+	if r.slave == r.master {
+		return nil
+	}
+	if err := r.slave.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get fetches the value of a hash field
+func (r *RedisStorage) Get(key, field string) (string, error) {
+	return r.slave.HGet(key, field).Result()
+}
+
+// GetMany fetches the values of multiple hash fields
+func (r *RedisStorage) GetMany(key string, fields ...string) ([]string, error) {
+	if len(fields) == 0 {
+		return []string{}, nil
+	}
+	vals, err := r.slave.HMGet(key, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, len(vals))
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %s of %s does not exist", fields[i], key)
+		}
+		values[i] = s
+	}
+	return values, nil
+}
+
+// Set sets the value of a hash field
+func (r *RedisStorage) Set(key, field, val string) error {
+	return r.master.HSet(key, field, val).Err()
+}
+
+// SetMany sets the values of multiple hash fields
+func (r *RedisStorage) SetMany(key string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(values))
+	for f, v := range values {
+		fields[f] = v
+	}
+	return r.master.HMSet(key, fields).Err()
+}
+
+// Delete deletes one or more hash fields
+func (r *RedisStorage) Delete(key string, fields ...string) error {
+	return r.master.HDel(key, fields...).Err()
+}
+
+// IsExist determines if a hash field exists
+func (r *RedisStorage) IsExist(key, field string) bool {
+	b, err := r.slave.HExists(key, field).Result()
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// AddSorted adds the members to the sorted set
+func (r *RedisStorage) AddSorted(key string, members ...SortedMember) error {
+	zs := make([]redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = redis.Z{Score: m.Score, Member: m.Member}
+	}
+	return r.master.ZAdd(key, zs...).Err()
+}
+
+// RangeSorted fetches the members of the sorted set within the scores
+func (r *RedisStorage) RangeSorted(key string, min, max float64) ([]string, error) {
+	return r.slave.ZRangeByScore(key, redis.ZRangeBy{
+		Min: strconv.FormatFloat(min, 'f', -1, 64),
+		Max: strconv.FormatFloat(max, 'f', -1, 64),
+	}).Result()
+}
+
+// ClearAll removes all keys starting with the prefix, every master of a
+// cluster is scanned for them
+func (r *RedisStorage) ClearAll(prefix string) (int64, error) {
+	cc, ok := r.master.(*redis.ClusterClient)
+	if !ok {
+		return clearPrefix(r.master, prefix)
+	}
+	var total int64
+	err := cc.ForEachMaster(func(c *redis.Client) error {
+		n, err := clearPrefix(c, prefix)
+		atomic.AddInt64(&total, n)
+		return err
+	})
+	return atomic.LoadInt64(&total), err
+}
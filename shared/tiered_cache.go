@@ -0,0 +1,221 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+)
+
+// Invalidator is implemented by the Cacher that could broadcast messages to
+// all the replicas of the function
+type Invalidator interface {
+	Publish(context.Context, string, string) error
+	// Subscribe calls the handler with every message of the channel until
+	// the returned Closer is closed
+	Subscribe(string, func(string)) (io.Closer, error)
+}
+
+// Sharer is implemented by the Cacher that keeps local copies of the
+// values, the ones that are not worth a local copy are kept only in the
+// cache shared by the replicas
+type Sharer interface {
+	GetShared(context.Context, string) ([]byte, bool, error)
+	SetShared(context.Context, string, []byte, time.Duration) error
+}
+
+const (
+	invalidateKey    = "key"
+	invalidatePrefix = "prefix"
+)
+
+// invalidation is the message about a key, or all keys with a prefix,
+// that are changed by a replica
+type invalidation struct {
+	Node string `json:"node"`
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+}
+
+// TieredCache is a Cacher that keeps recently used values of a shared
+// cache(L2) in a local one(L1). Reads are served from L1 and fall through
+// to L2 on a miss, writes go to both. Changes are broadcast if L2 is an
+// Invalidator so that the other replicas drop their stale L1 entries.
+type TieredCache struct {
+	l1      Cacher
+	l2      Cacher
+	ttl     time.Duration
+	node    string
+	channel string
+	closer  io.Closer
+}
+
+// NewTieredCache is the constructor for TieredCache, the values are kept
+// in l1 for at most ttl and never longer than they are kept in l2. The
+// replicas announce the keys that have to be dropped from their l1 on the
+// channel.
+func NewTieredCache(l1, l2 Cacher, ttl time.Duration, channel string) Cacher {
+	t := &TieredCache{l1: l1, l2: l2, ttl: ttl, channel: channel}
+	node, err := NewToken()
+	if err != nil {
+		log.Printf("error in generating node id %s", err)
+	}
+	t.node = node
+	inv, ok := l2.(Invalidator)
+	if !ok {
+		return t
+	}
+	closer, err := inv.Subscribe(channel, t.invalidate)
+	if err != nil {
+		log.Printf("error in subscribing to %s %s", channel, err)
+		return t
+	}
+	t.closer = closer
+	return t
+}
+
+// Close stops listening for invalidations
+func (t *TieredCache) Close() error {
+	if t.closer == nil {
+		return nil
+	}
+	return t.closer.Close()
+}
+
+func (t *TieredCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, _, ok, err := t.GetWithTTL(ctx, key)
+	return v, ok, err
+}
+
+// GetWithTTL looks up L1 first, a value found in L2 is kept in L1 for
+// no longer than it is kept in L2
+func (t *TieredCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	v, d, ok, err := t.l1.GetWithTTL(ctx, key)
+	if err != nil {
+		log.Printf("error in getting local key %s %s", key, err)
+	}
+	if err == nil && ok {
+		return v, d, true, nil
+	}
+	v, d, ok, err = t.l2.GetWithTTL(ctx, key)
+	if err != nil || !ok {
+		return nil, 0, false, err
+	}
+	if err := t.l1.Set(ctx, key, v, t.localTTL(d)); err != nil {
+		log.Printf("error in setting local key %s %s", key, err)
+	}
+	return v, d, true, nil
+}
+
+func (t *TieredCache) Set(ctx context.Context, key string, val []byte, d time.Duration) error {
+	if err := t.l2.Set(ctx, key, val, d); err != nil {
+		return err
+	}
+	t.publish(ctx, &invalidation{Kind: invalidateKey, Key: key})
+	return t.l1.Set(ctx, key, val, t.localTTL(d))
+}
+
+// GetShared looks up only L2, the value is not kept in L1
+func (t *TieredCache) GetShared(ctx context.Context, key string) ([]byte, bool, error) {
+	return t.l2.Get(ctx, key)
+}
+
+// SetShared stores the value only in L2
+func (t *TieredCache) SetShared(ctx context.Context, key string, val []byte, d time.Duration) error {
+	return t.l2.Set(ctx, key, val, d)
+}
+
+func (t *TieredCache) Delete(ctx context.Context, key string) error {
+	if err := t.l1.Delete(ctx, key); err != nil {
+		return err
+	}
+	if err := t.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	t.publish(ctx, &invalidation{Kind: invalidateKey, Key: key})
+	return nil
+}
+
+// ClearAll removes all keys starting with the prefix from both the tiers
+// and returns the number of keys removed from L2
+func (t *TieredCache) ClearAll(ctx context.Context, prefix string) (int64, error) {
+	if _, err := t.l1.ClearAll(ctx, prefix); err != nil {
+		return 0, err
+	}
+	n, err := t.l2.ClearAll(ctx, prefix)
+	if err != nil {
+		return n, err
+	}
+	t.publish(ctx, &invalidation{Kind: invalidatePrefix, Key: prefix})
+	return n, nil
+}
+
+// Lock acquires the lock from L2, it is always acquired if L2 is not a
+// Locker
+func (t *TieredCache) Lock(ctx context.Context, key, token string, d time.Duration) (bool, error) {
+	if l, ok := t.l2.(Locker); ok {
+		return l.Lock(ctx, key, token, d)
+	}
+	return true, nil
+}
+
+func (t *TieredCache) Unlock(ctx context.Context, key, token string) error {
+	if l, ok := t.l2.(Locker); ok {
+		return l.Unlock(ctx, key, token)
+	}
+	return nil
+}
+
+func (t *TieredCache) Extend(ctx context.Context, key, token string, d time.Duration) (bool, error) {
+	if l, ok := t.l2.(Locker); ok {
+		return l.Extend(ctx, key, token, d)
+	}
+	return true, nil
+}
+
+func (t *TieredCache) localTTL(d time.Duration) time.Duration {
+	if d > 0 && d < t.ttl {
+		return d
+	}
+	return t.ttl
+}
+
+func (t *TieredCache) publish(ctx context.Context, inv *invalidation) {
+	p, ok := t.l2.(Invalidator)
+	if !ok {
+		return
+	}
+	inv.Node = t.node
+	b, err := json.Marshal(inv)
+	if err != nil {
+		log.Printf("error in encoding invalidation %s", err)
+		return
+	}
+	if err := p.Publish(ctx, t.channel, string(b)); err != nil {
+		log.Printf("error in publishing invalidation %s", err)
+	}
+}
+
+// invalidate drops the local entries announced by the other replicas
+func (t *TieredCache) invalidate(msg string) {
+	inv := &invalidation{}
+	if err := json.Unmarshal([]byte(msg), inv); err != nil {
+		log.Printf("error in decoding invalidation %s %s", msg, err)
+		return
+	}
+	if inv.Node == t.node {
+		return
+	}
+	ctx := context.Background()
+	switch inv.Kind {
+	case invalidateKey:
+		if err := t.l1.Delete(ctx, inv.Key); err != nil {
+			log.Printf("error in invalidating local key %s %s", inv.Key, err)
+		}
+	case invalidatePrefix:
+		if _, err := t.l1.ClearAll(ctx, inv.Key); err != nil {
+			log.Printf("error in invalidating local keys %s %s", inv.Key, err)
+		}
+	}
+}
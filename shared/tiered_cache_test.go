@@ -0,0 +1,27 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTieredCacheLocalTTL(t *testing.T) {
+	tc := &TieredCache{ttl: 5 * time.Minute}
+	tests := []struct {
+		name string
+		l2   time.Duration
+		want time.Duration
+	}{
+		{"without expiry in l2", 0, 5 * time.Minute},
+		{"expires sooner in l2", time.Minute, time.Minute},
+		{"expires later in l2", time.Hour, 5 * time.Minute},
+		{"same as l1", 5 * time.Minute, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tc.localTTL(tt.l2); got != tt.want {
+				t.Errorf("localTTL(%s) = %s, want %s", tt.l2, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package shared
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisUniversalCache is a Cacher for the redis deployments where the
+// client finds the master by itself, either through sentinel or as a
+// cluster
+type RedisUniversalCache struct {
+	client redis.UniversalClient
+}
+
+// NewRedisSentinelCache is the constructor for RedisUniversalCache that
+// connects to the master known to the sentinels by the given name
+func NewRedisSentinelCache(masterName string, sentinels []string, opts *RedisOptions) Cacher {
+	return &RedisUniversalCache{
+		client: redis.NewFailoverClient(opts.failover(masterName, sentinels)),
+	}
+}
+
+// NewRedisClusterCache is the constructor for RedisUniversalCache that
+// connects to a redis cluster through the given nodes
+func NewRedisClusterCache(addrs []string, opts *RedisOptions) Cacher {
+	return &RedisUniversalCache{
+		client: redis.NewClusterClient(opts.cluster(addrs)),
+	}
+}
+
+// cmd returns the client that runs the commands with the context
+func (r *RedisUniversalCache) cmd(ctx context.Context) redis.Cmdable {
+	switch c := r.client.(type) {
+	case *redis.Client:
+		return c.WithContext(ctx)
+	case *redis.ClusterClient:
+		return c.WithContext(ctx)
+	}
+	return r.client
+}
+
+func (r *RedisUniversalCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := r.cmd(ctx).Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+func (r *RedisUniversalCache) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, bool, error) {
+	v, d, err := getWithTTL(r.cmd(ctx), key)
+	if err == redis.Nil {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return v, d, true, nil
+}
+
+func (r *RedisUniversalCache) Set(ctx context.Context, key string, val []byte, t time.Duration) error {
+	return r.cmd(ctx).Set(key, val, t).Err()
+}
+
+func (r *RedisUniversalCache) Delete(ctx context.Context, key string) error {
+	return r.cmd(ctx).Del(key).Err()
+}
+
+func (r *RedisUniversalCache) Lock(ctx context.Context, key, token string, t time.Duration) (bool, error) {
+	return r.cmd(ctx).SetNX(key, token, t).Result()
+}
+
+func (r *RedisUniversalCache) Unlock(ctx context.Context, key, token string) error {
+	return r.cmd(ctx).Eval(unlockScript, []string{key}, token).Err()
+}
+
+func (r *RedisUniversalCache) Extend(ctx context.Context, key, token string, t time.Duration) (bool, error) {
+	v, err := r.cmd(ctx).Eval(
+		extendScript, []string{key}, token, int64(t/time.Millisecond),
+	).Result()
+	if err != nil {
+		return false, err
+	}
+	n, ok := v.(int64)
+	return ok && n == 1, nil
+}
+
+func (r *RedisUniversalCache) Publish(ctx context.Context, channel, msg string) error {
+	return r.cmd(ctx).Publish(channel, msg).Err()
+}
+
+// Subscribe listens to the channel in the background
+func (r *RedisUniversalCache) Subscribe(channel string, fn func(string)) (io.Closer, error) {
+	return receiveChannel(r.client.Subscribe(channel), fn)
+}
+
+// ClearAll removes all keys starting with the prefix and returns the
+// number of keys removed, every master of a cluster is scanned for them
+func (r *RedisUniversalCache) ClearAll(ctx context.Context, prefix string) (int64, error) {
+	cc, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		return clearPrefix(r.cmd(ctx), prefix)
+	}
+	var total int64
+	err := cc.ForEachMaster(func(c *redis.Client) error {
+		n, err := clearPrefix(c.WithContext(ctx), prefix)
+		atomic.AddInt64(&total, n)
+		return err
+	})
+	return atomic.LoadInt64(&total), err
+}
@@ -3,6 +3,9 @@ module github.com/dictybase-playground/kubeless-gofn/uniprot
 go 1.13
 
 require (
-	github.com/go-redis/redis v6.14.1+incompatible
+	github.com/dictybase-playground/kubeless-gofn/shared v0.0.0
+	github.com/go-redis/redis v6.14.1+incompatible // indirect
 	github.com/kubeless/kubeless v1.0.7
 )
+
+replace github.com/dictybase-playground/kubeless-gofn/shared => ../shared
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 
+	"github.com/dictybase-playground/kubeless-gofn/shared"
 	"github.com/kubeless/kubeless/pkg/functions"
 )
 
@@ -18,20 +18,13 @@ const (
 	URL = "https://www.uniprot.org/uniprot/?query=taxonomy:44689&columns=id,database(dictyBase),genes(PREFERRED)&format=tab"
 )
 
-func getStorage() (Storage, error) {
-	var st Storage
-	rhost := os.Getenv("REDIS_MASTER_SERVICE_HOST")
-	rport := os.Getenv("REDIS_MASTER_SERVICE_PORT")
-	shost := os.Getenv("REDIS_SLAVE_SERVICE_HOST")
-	sport := os.Getenv("REDIS_SLAVE_SERVICE_PORT")
-	if len(rhost) > 0 && len(shost) > 0 {
-		st = NewRedisStorage(
-			fmt.Sprintf("%s:%s", rhost, rport),
-			fmt.Sprintf("%s:%s", shost, sport),
-		)
-		return st, nil
-	}
-	return st, fmt.Errorf("no storage backend available")
+// getStorage returns the redis Storage configured from the environment,
+// the values are never compressed since the mapping is read by other
+// services
+func getStorage() (shared.Storage, error) {
+	c := shared.RedisConfigFromEnv()
+	c.Compression = false
+	return shared.NewStorage(c)
 }
 
 // CacheIds stores uniprot and gene name or identifier mapping in redis